@@ -0,0 +1,93 @@
+package tinycrypto
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Algorithm identifies the AEAD cipher a Key uses. The zero value,
+// AlgAES256GCM, keeps existing Keys that never set it working unchanged.
+type Algorithm uint8
+
+// Supported algorithms.
+const (
+	// AlgAES256GCM is AES-256 in Galois/Counter Mode, with a 96-bit nonce.
+	// It's the long-standing default, and the only algorithm a Key could use
+	// before Algorithm existed.
+	AlgAES256GCM Algorithm = iota
+
+	// AlgChaCha20Poly1305 is ChaCha20-Poly1305 (RFC 8439), with a 96-bit
+	// nonce.
+	AlgChaCha20Poly1305
+
+	// AlgXChaCha20Poly1305 is the extended-nonce variant of ChaCha20-Poly1305,
+	// with a 192-bit nonce. Prefer it over AlgChaCha20Poly1305 or
+	// AlgAES256GCM for long-lived keys that will encrypt a large number of
+	// messages, where a 96-bit random nonce risks collision.
+	AlgXChaCha20Poly1305
+
+	// AlgAESGCMSIV is AES-256-GCM-SIV (RFC 8452), which tolerates nonce reuse
+	// without catastrophic loss of confidentiality or integrity. Prefer it
+	// when the caller can't guarantee nonce uniqueness.
+	AlgAESGCMSIV
+)
+
+// valid reports whether a is one of the recognized Algorithm values.
+func (a Algorithm) valid() bool {
+	switch a {
+	case AlgAES256GCM, AlgChaCha20Poly1305, AlgXChaCha20Poly1305, AlgAESGCMSIV:
+		return true
+	default:
+		return false
+	}
+}
+
+// cipherTextVersion is the version byte prepended to ciphertexts produced by
+// EncryptWithKey, ahead of the algorithm ID and nonce. Bumping it is reserved
+// for future, incompatible framing changes.
+const cipherTextVersion byte = 1
+
+// EncryptWithKey authenticates and encrypts val under key, dispatching on
+// key.Algorithm, and authenticates (without encrypting) additionalData. The
+// ciphertext is self-describing: a 1-byte version, a 1-byte algorithm ID, the
+// nonce, and finally the sealed data, in that order, so `DecryptWithKey` (or
+// `Keyset.Decrypt`) can pick the right primitive without being told which key
+// produced it.
+func EncryptWithKey(key *Key, val, additionalData []byte) ([]byte, error) {
+	nonce, err := key.NewRandomNonce()
+	if err != nil {
+		return nil, err
+	}
+	out := make([]byte, 0, 2+len(nonce)+len(val)+key.Overhead())
+	out = append(out, cipherTextVersion, byte(key.Algorithm))
+	out = append(out, nonce...)
+	return key.Seal(out, nonce, val, additionalData), nil
+}
+
+// DecryptWithKey decrypts a ciphertext produced by EncryptWithKey under key,
+// verifying additionalData. It refuses to decrypt when the ciphertext's
+// version or algorithm ID don't match what's expected, or when its algorithm
+// ID isn't recognized at all.
+func DecryptWithKey(key *Key, val, additionalData []byte) ([]byte, error) {
+	if len(val) < 2 {
+		return nil, errors.New("tinycrypto: the cipher text value is too short")
+	}
+	version, alg := val[0], Algorithm(val[1])
+	if version != cipherTextVersion {
+		return nil, fmt.Errorf("tinycrypto: unsupported ciphertext version %d", version)
+	}
+	if !alg.valid() {
+		return nil, fmt.Errorf("tinycrypto: unrecognized algorithm id %d", alg)
+	}
+	if alg != key.Algorithm {
+		return nil, fmt.Errorf("tinycrypto: ciphertext algorithm %d does not match key algorithm %d", alg, key.Algorithm)
+	}
+
+	rest := val[2:]
+	nonceSize := key.NonceSize()
+	if len(rest) < nonceSize {
+		return nil, errors.New("tinycrypto: the cipher text value is too short")
+	}
+	nonce, ciphertext := rest[:nonceSize], rest[nonceSize:]
+	return key.Open(nil, nonce, ciphertext, additionalData)
+}
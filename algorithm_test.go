@@ -0,0 +1,62 @@
+package tinycrypto
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestEncryptWithKeyDecryptWithKey_AllAlgorithms(t *testing.T) {
+	plaintext := []byte("this is my secret value that I must protect")
+	aad := []byte("associated data")
+
+	for _, alg := range []Algorithm{AlgAES256GCM, AlgChaCha20Poly1305, AlgXChaCha20Poly1305, AlgAESGCMSIV} {
+		key, err := NewRandomKey()
+		if err != nil {
+			t.Fatalf("unable to generate new key: %s", err.Error())
+		}
+		key.Algorithm = alg
+
+		ciphertext, err := EncryptWithKey(key, plaintext, aad)
+		if err != nil {
+			t.Fatalf("algorithm %d: the encryption process failed: %s", alg, err.Error())
+		}
+		decrypted, err := DecryptWithKey(key, ciphertext, aad)
+		if err != nil {
+			t.Fatalf("algorithm %d: the decryption process failed: %s", alg, err.Error())
+		}
+		if !bytes.Equal(decrypted, plaintext) {
+			t.Fatalf("algorithm %d: expected decrypted to be %q, but found %q", alg, plaintext, decrypted)
+		}
+	}
+}
+
+func TestDecryptWithKey_RejectsUnrecognizedAlgorithm(t *testing.T) {
+	key, _ := NewRandomKey()
+	ciphertext, err := EncryptWithKey(key, []byte("hello"), nil)
+	if err != nil {
+		t.Fatalf("the encryption process failed: %s", err.Error())
+	}
+	ciphertext[1] = 0xFF // corrupt the algorithm ID
+
+	if _, err := DecryptWithKey(key, ciphertext, nil); err == nil {
+		t.Fatal("expected an unrecognized algorithm id to be rejected, got nil error")
+	}
+}
+
+func TestKeyset_Decrypt_LegacyCiphertext(t *testing.T) {
+	plaintext := []byte("this is my secret value that I must protect")
+	key, _ := NewRandomKey()
+	ks := NewKeysetWithKey(key)
+
+	legacyCiphertext, err := Encrypt(plaintext, key.Value)
+	if err != nil {
+		t.Fatalf("the encryption process failed: %s", err.Error())
+	}
+	decrypted, err := ks.Decrypt(legacyCiphertext)
+	if err != nil {
+		t.Fatalf("expected a legacy (unversioned) ciphertext to still decrypt: %s", err.Error())
+	}
+	if !bytes.Equal(decrypted, plaintext) {
+		t.Fatalf("expected decrypted to be %q, but found %q", plaintext, decrypted)
+	}
+}
@@ -15,6 +15,10 @@ import (
 	"io"
 	"sync"
 	"time"
+
+	"golang.org/x/crypto/chacha20poly1305"
+
+	"github.com/bitdabbler/tinycrypto/kdf"
 )
 
 // HashForString converts a string into a 256-bit hash, usable as a secret key
@@ -28,38 +32,31 @@ func HashForString(s string) []byte {
 // Encrypt leverages AES-GCM authenticated encryption (encrypts and signs).
 // https://en.wikipedia.org/wiki/Galois/Counter_Mode NOTE: This is for safely
 // storing secret keys. If you need to hash a password, use the acrypt lib.
+//
+// Encrypt is a thin wrapper around the `Key` AEAD: it generates a fresh random
+// nonce and prepends it to the returned ciphertext. Callers that need to
+// supply their own nonce (streaming pipelines, deterministic tests, packet
+// protocols) should use `SealWithNonce` instead.
 func Encrypt(val, key []byte) ([]byte, error) {
-	c, err := aes.NewCipher(key)
+	k := &Key{Value: key}
+	nonce, err := k.NewRandomNonce()
 	if err != nil {
 		return nil, err
 	}
-	gcm, err := cipher.NewGCM(c)
-	if err != nil {
-		return nil, err
-	}
-	nonce := make([]byte, gcm.NonceSize())
-	if _, err = io.ReadFull(rand.Reader, nonce); err != nil {
-		return nil, err
-	}
-	return gcm.Seal(nonce, nonce, val, nil), nil
+	return k.Seal(nonce, nonce, val, nil), nil
 }
 
-// Decrypt decrypts an AES-GCM encrypted value.
+// Decrypt decrypts an AES-GCM encrypted value produced by `Encrypt`, i.e. one
+// with the nonce prepended to the ciphertext. Callers that manage their own
+// nonces should use `OpenWithNonce` instead.
 func Decrypt(val []byte, key []byte) ([]byte, error) {
-	c, err := aes.NewCipher(key)
-	if err != nil {
-		return nil, err
-	}
-	gcm, err := cipher.NewGCM(c)
-	if err != nil {
-		return nil, err
-	}
-	nonceSize := gcm.NonceSize()
+	k := &Key{Value: key}
+	nonceSize := k.NonceSize()
 	if len(val) < nonceSize {
 		return nil, errors.New("the cipher text value is too short")
 	}
 	nonce, val := val[:nonceSize], val[nonceSize:]
-	return gcm.Open(nil, nonce, val, nil)
+	return k.Open(nil, nonce, val, nil)
 }
 
 // A Keyset stores multiple keys, allowing clients to rotate keys if required.
@@ -87,9 +84,25 @@ func NewKeysetWithKey(k *Key) *Keyset {
 	}
 }
 
-// Key wraps an encryption key value to be used with `Keyset`s.
+// NewKeysetWithPassphrase constructs a new Keyset whose first Key is derived
+// from passphrase and salt using params, e.g. kdf.DefaultScryptParams() or
+// the output of kdf.CalibrateParams. This lets callers bootstrap a Keyset
+// directly from an operator-memorable passphrase instead of misusing
+// HashForString, which is only suitable for already-random secrets.
+func NewKeysetWithPassphrase(passphrase, salt []byte, params kdf.Params) (*Keyset, error) {
+	k, err := NewKeyFromPassphrase(passphrase, salt, params)
+	if err != nil {
+		return nil, err
+	}
+	return NewKeysetWithKey(k), nil
+}
+
+// Key wraps an encryption key value to be used with `Keyset`s. The zero value
+// for Algorithm is AlgAES256GCM, so existing callers that never set it keep
+// getting AES-256-GCM.
 type Key struct {
 	Value       []byte
+	Algorithm   Algorithm
 	CreatedUnix int64
 	ExpiresUnix int64
 }
@@ -113,8 +126,128 @@ func NewKey(key256 []byte) *Key {
 	}
 }
 
-// Encrypt leverages AES-GCM authenticated encryption using the first encryption
-// key in they Keyset.
+// NewKeyFromPassphrase derives a Key from passphrase and salt using params,
+// e.g. kdf.DefaultScryptParams() or the output of kdf.CalibrateParams. Use
+// this (rather than HashForString) when the secret originates as a
+// human-chosen passphrase rather than an already-random value.
+func NewKeyFromPassphrase(passphrase, salt []byte, params kdf.Params) (*Key, error) {
+	dk, err := kdf.DeriveKey(passphrase, salt, params)
+	if err != nil {
+		return nil, fmt.Errorf("unable to derive key from passphrase: %w", err)
+	}
+	return NewKey(dk), nil
+}
+
+// Key implements cipher.AEAD, using whichever primitive its Algorithm
+// selects, so it can be passed directly to anything in the standard library,
+// or elsewhere, that consumes an AEAD, and so callers that need explicit
+// control over nonces aren't forced through `Encrypt`/`Decrypt`.
+var _ cipher.AEAD = (*Key)(nil)
+
+// aead builds the underlying AEAD for the key's value and Algorithm.
+func (k *Key) aead() (cipher.AEAD, error) {
+	switch k.Algorithm {
+	case AlgAES256GCM:
+		block, err := aes.NewCipher(k.Value)
+		if err != nil {
+			return nil, err
+		}
+		return cipher.NewGCM(block)
+	case AlgChaCha20Poly1305:
+		return chacha20poly1305.New(k.Value)
+	case AlgXChaCha20Poly1305:
+		return chacha20poly1305.NewX(k.Value)
+	case AlgAESGCMSIV:
+		return newAESGCMSIV(k.Value)
+	default:
+		return nil, fmt.Errorf("tinycrypto: unrecognized algorithm %d", k.Algorithm)
+	}
+}
+
+// mustAEAD builds the underlying AEAD for the key, panicking on failure (an
+// invalid key value or unrecognized Algorithm), the same way the standard
+// library panics on other cipher misuse, since that indicates a programming
+// error rather than something a caller can recover from.
+func (k *Key) mustAEAD() cipher.AEAD {
+	a, err := k.aead()
+	if err != nil {
+		panic("tinycrypto: " + err.Error())
+	}
+	return a
+}
+
+// NonceSize returns the size, in bytes, of nonces this key expects to be
+// passed to Seal or Open.
+func (k *Key) NonceSize() int {
+	return k.mustAEAD().NonceSize()
+}
+
+// Overhead returns the maximum difference between the lengths of a plaintext
+// and its ciphertext.
+func (k *Key) Overhead() int {
+	return k.mustAEAD().Overhead()
+}
+
+// Seal encrypts and authenticates plaintext, authenticates the additional
+// data and appends the result to dst, returning the updated slice. The nonce
+// must be NonceSize() bytes long and unique for all time, for a given key.
+func (k *Key) Seal(dst, nonce, plaintext, additionalData []byte) []byte {
+	return k.mustAEAD().Seal(dst, nonce, plaintext, additionalData)
+}
+
+// Open decrypts and authenticates ciphertext, authenticates the additional
+// data and, if successful, appends the resulting plaintext to dst, returning
+// the updated slice.
+func (k *Key) Open(dst, nonce, ciphertext, additionalData []byte) ([]byte, error) {
+	return k.mustAEAD().Open(dst, nonce, ciphertext, additionalData)
+}
+
+// NewRandomNonce generates a random nonce of the size this key's AEAD
+// expects, suitable for passing to `SealWithNonce`.
+func (k *Key) NewRandomNonce() ([]byte, error) {
+	return GenerateRandomBytes(uint32(k.NonceSize()))
+}
+
+// SealWithNonce seals plaintext under the given key using the caller-supplied
+// nonce and additional authenticated data, returning just the ciphertext
+// (unlike `Encrypt`, the nonce is not prepended). This lets callers plug
+// tinycrypto into streaming pipelines, chunked file encryption, deterministic
+// tests, or packet protocols that already carry a nonce out of band.
+//
+// All-zero nonces are rejected, since they're almost always a sign the caller
+// forgot to generate one (e.g. via `NewRandomNonce`) rather than an
+// intentional choice.
+func SealWithNonce(k *Key, nonce, plaintext, additionalData []byte) ([]byte, error) {
+	if isZeroNonce(nonce) {
+		return nil, errors.New("tinycrypto: refusing to seal with an all-zero nonce")
+	}
+	return k.Seal(nil, nonce, plaintext, additionalData), nil
+}
+
+// OpenWithNonce opens ciphertext produced by `SealWithNonce`, using the same
+// caller-supplied nonce and additional authenticated data.
+func OpenWithNonce(k *Key, nonce, ciphertext, additionalData []byte) ([]byte, error) {
+	if isZeroNonce(nonce) {
+		return nil, errors.New("tinycrypto: refusing to open with an all-zero nonce")
+	}
+	return k.Open(nil, nonce, ciphertext, additionalData)
+}
+
+// isZeroNonce reports whether nonce consists entirely of zero bytes.
+func isZeroNonce(nonce []byte) bool {
+	for _, b := range nonce {
+		if b != 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// Encrypt authenticates and encrypts val using the first encryption key in
+// the Keyset, under that key's Algorithm. The returned ciphertext is
+// self-describing (it carries a version, that key's ID, and its algorithm
+// ID ahead of the nonce), so `Decrypt` can look the right key and primitive
+// up by ID (see `KeyByID`), rather than trial-decrypting against every key.
 func (ks *Keyset) Encrypt(val []byte) ([]byte, error) {
 	ks.RLock()
 	defer ks.RUnlock()
@@ -126,12 +259,24 @@ func (ks *Keyset) Encrypt(val []byte) ([]byte, error) {
 	if k.ExpiresUnix > 0 && k.ExpiresUnix < time.Now().Unix() {
 		return nil, errors.New("no valid key in keyset")
 	}
-	return Encrypt(val, k.Value)
+	return sealWithKeyID(k, val, nil)
 }
 
-// Decrypt attempts to decrypt an AES-GCM encrypted value using each unexpired
-// key in the given keyset until decryption is successful.
+// Decrypt decrypts a value encrypted by `Encrypt`. Current ciphertexts carry
+// a key ID, so the right key is found by ID without trial decryption. For
+// migration, it also understands the older version+algorithm-tagged format
+// (no key ID), and legacy ciphertexts (no version byte at all),
+// trial-decrypting against each unexpired key for those. A value that starts
+// with the key-ID version byte but fails to open by ID is also tried in the
+// older formats, since a legacy ciphertext's random nonce can start with
+// that byte.
 func (ks *Keyset) Decrypt(val []byte) (res []byte, err error) {
+	if len(val) > 0 && val[0] == cipherTextVersionWithKeyID {
+		if res, err = openWithKeyID(ks, val, nil); err == nil {
+			return res, nil
+		}
+	}
+
 	ks.RLock()
 	defer ks.RUnlock()
 
@@ -140,10 +285,14 @@ func (ks *Keyset) Decrypt(val []byte) (res []byte, err error) {
 		if k.ExpiresUnix > 0 && k.ExpiresUnix < now {
 			continue
 		}
-		res, err = Decrypt(val, k.Value)
-		if err == nil {
+		if res, err = DecryptWithKey(k, val, nil); err == nil {
 			return res, nil
 		}
+		if k.Algorithm == AlgAES256GCM {
+			if res, err = Decrypt(val, k.Value); err == nil {
+				return res, nil
+			}
+		}
 	}
 	return nil, errors.New("no valid decryption key")
 }
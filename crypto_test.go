@@ -1,6 +1,7 @@
 package tinycrypto
 
 import (
+	"bytes"
 	"log"
 	"testing"
 	"time"
@@ -64,6 +65,41 @@ func TestCryptoKeyset(t *testing.T) {
 	log.Print("ok")
 }
 
+func TestKey_SealWithNonceOpenWithNonce(t *testing.T) {
+	key, _ := NewRandomKey()
+	plaintext := []byte("this is my secret value that I must protect")
+	aad := []byte("associated data")
+
+	nonce, err := key.NewRandomNonce()
+	if err != nil {
+		t.Fatalf("unable to generate nonce: %s", err.Error())
+	}
+	ciphertext, err := SealWithNonce(key, nonce, plaintext, aad)
+	if err != nil {
+		t.Fatalf("the seal process failed: %s", err.Error())
+	}
+	decrypted, err := OpenWithNonce(key, nonce, ciphertext, aad)
+	if err != nil {
+		t.Fatalf("the open process failed: %s", err.Error())
+	}
+	if !bytes.Equal(decrypted, plaintext) {
+		t.Fatalf("expected decrypted to be %q, but found %q", plaintext, decrypted)
+	}
+}
+
+func TestSealWithNonceOpenWithNonce_RejectZeroNonce(t *testing.T) {
+	key, _ := NewRandomKey()
+	zeroNonce := make([]byte, key.NonceSize())
+	plaintext := []byte("this is my secret value that I must protect")
+
+	if _, err := SealWithNonce(key, zeroNonce, plaintext, nil); err == nil {
+		t.Fatal("expected SealWithNonce to reject an all-zero nonce, got nil error")
+	}
+	if _, err := OpenWithNonce(key, zeroNonce, plaintext, nil); err == nil {
+		t.Fatal("expected OpenWithNonce to reject an all-zero nonce, got nil error")
+	}
+}
+
 func TestKeyset_RotateIn(t *testing.T) {
 	k1, _ := NewRandomKey()
 	k2, _ := NewRandomKey()
@@ -0,0 +1,157 @@
+package tinycrypto
+
+import (
+	"context"
+	"fmt"
+)
+
+// KeyProvider wraps and unwraps data encryption keys (DEKs) under a key
+// encryption key (KEK) that the provider manages. `EnvelopeEncrypter` uses a
+// KeyProvider so that rotating the KEK never requires re-encrypting every
+// stored ciphertext: only the (small) wrapped DEK needs to be re-wrapped.
+type KeyProvider interface {
+	// Wrap encrypts dek under the provider's current KEK, returning an
+	// identifier for that KEK alongside the wrapped key.
+	Wrap(dek []byte) (kekID string, wrapped []byte, err error)
+	// Unwrap decrypts a DEK that was wrapped under the KEK identified by
+	// kekID.
+	Unwrap(kekID string, wrapped []byte) (dek []byte, err error)
+}
+
+// KeysetKeyProvider is the default, in-process `KeyProvider`, backed by a
+// `Keyset` acting as the KEK. Rotating the KEK is just `Keyset.RotateIn`.
+type KeysetKeyProvider struct {
+	Keyset *Keyset
+}
+
+var _ KeyProvider = (*KeysetKeyProvider)(nil)
+
+// NewKeysetKeyProvider constructs a KeysetKeyProvider backed by ks.
+func NewKeysetKeyProvider(ks *Keyset) *KeysetKeyProvider {
+	return &KeysetKeyProvider{Keyset: ks}
+}
+
+// Wrap encrypts dek with the Keyset's current encryption key. It returns an
+// empty kekID: the wrapped ciphertext itself carries the wrapping key's ID
+// (see Unwrap).
+func (p *KeysetKeyProvider) Wrap(dek []byte) (kekID string, wrapped []byte, err error) {
+	wrapped, err = p.Keyset.Encrypt(dek)
+	return "", wrapped, err
+}
+
+// Unwrap decrypts a DEK previously wrapped by this Keyset. kekID is unused:
+// the ciphertext `Keyset.Decrypt` receives already carries the wrapping
+// key's ID, so it looks the right key up in the Keyset directly (falling
+// back to trial decryption only for ciphertexts from before keys had IDs),
+// without this KeyProvider needing to track kekIDs itself.
+func (p *KeysetKeyProvider) Unwrap(kekID string, wrapped []byte) ([]byte, error) {
+	return p.Keyset.Decrypt(wrapped)
+}
+
+// RemoteKeyProvider is the interface to implement to back an
+// `EnvelopeEncrypter` with an external KMS (AWS KMS, GCP KMS, HashiCorp
+// Vault, etc.) instead of the in-process `KeysetKeyProvider`. It's
+// context-aware, since wrapping and unwrapping a DEK against a remote KMS is
+// a network call. Use `RemoteKeyProviderAdapter` to plug one into an
+// `EnvelopeEncrypter`, which expects the (context-free) `KeyProvider`.
+type RemoteKeyProvider interface {
+	Wrap(ctx context.Context, dek []byte) (kekID string, wrapped []byte, err error)
+	Unwrap(ctx context.Context, kekID string, wrapped []byte) (dek []byte, err error)
+}
+
+// RemoteKeyProviderAdapter adapts a RemoteKeyProvider to the KeyProvider
+// interface that EnvelopeEncrypter expects, issuing every call with Ctx (or
+// context.Background(), if Ctx is nil).
+type RemoteKeyProviderAdapter struct {
+	Provider RemoteKeyProvider
+	Ctx      context.Context
+}
+
+var _ KeyProvider = (*RemoteKeyProviderAdapter)(nil)
+
+func (a *RemoteKeyProviderAdapter) ctx() context.Context {
+	if a.Ctx != nil {
+		return a.Ctx
+	}
+	return context.Background()
+}
+
+// Wrap calls the underlying RemoteKeyProvider's Wrap.
+func (a *RemoteKeyProviderAdapter) Wrap(dek []byte) (kekID string, wrapped []byte, err error) {
+	return a.Provider.Wrap(a.ctx(), dek)
+}
+
+// Unwrap calls the underlying RemoteKeyProvider's Unwrap.
+func (a *RemoteKeyProviderAdapter) Unwrap(kekID string, wrapped []byte) ([]byte, error) {
+	return a.Provider.Unwrap(a.ctx(), kekID, wrapped)
+}
+
+// EnvelopeBlob is the self-contained output of `EnvelopeEncrypter.Encrypt`:
+// everything needed to recover the plaintext, given access to the KeyProvider
+// that wrapped the DEK.
+type EnvelopeBlob struct {
+	KEKID      string
+	WrappedDEK []byte
+	Nonce      []byte
+	Ciphertext []byte
+	AAD        []byte
+}
+
+// EnvelopeEncrypter implements envelope (DEK/KEK) encryption on top of a
+// KeyProvider: each `Encrypt` call generates a fresh, random, AES-256-GCM
+// data encryption key, encrypts the payload with it, and wraps the DEK under
+// the provider's KEK, so rotating the KEK (e.g. via
+// `Keyset.RotateIn`) never requires touching previously stored ciphertexts,
+// and each payload gets its own DEK.
+type EnvelopeEncrypter struct {
+	Provider KeyProvider
+}
+
+// NewEnvelopeEncrypter constructs an EnvelopeEncrypter backed by provider.
+func NewEnvelopeEncrypter(provider KeyProvider) *EnvelopeEncrypter {
+	return &EnvelopeEncrypter{Provider: provider}
+}
+
+// Encrypt generates a fresh DEK, seals plaintext (and authenticates aad)
+// under it, wraps the DEK with the EnvelopeEncrypter's KeyProvider, and
+// returns the resulting self-contained blob.
+func (e *EnvelopeEncrypter) Encrypt(plaintext, aad []byte) (*EnvelopeBlob, error) {
+	dek, err := NewRandomKey()
+	if err != nil {
+		return nil, fmt.Errorf("envelope: unable to generate data encryption key: %w", err)
+	}
+	nonce, err := dek.NewRandomNonce()
+	if err != nil {
+		return nil, fmt.Errorf("envelope: unable to generate nonce: %w", err)
+	}
+	ciphertext := dek.Seal(nil, nonce, plaintext, aad)
+
+	kekID, wrappedDEK, err := e.Provider.Wrap(dek.Value)
+	if err != nil {
+		return nil, fmt.Errorf("envelope: unable to wrap data encryption key: %w", err)
+	}
+	return &EnvelopeBlob{
+		KEKID:      kekID,
+		WrappedDEK: wrappedDEK,
+		Nonce:      nonce,
+		Ciphertext: ciphertext,
+		AAD:        aad,
+	}, nil
+}
+
+// Decrypt unwraps blob's DEK with the EnvelopeEncrypter's KeyProvider, then
+// opens its ciphertext. The unwrapped DEK is validated before use: it comes
+// from the KeyProvider, which is typically backed by a network call to an
+// external KMS, so a buggy provider or a corrupted response is treated as a
+// decryption failure rather than allowed to panic.
+func (e *EnvelopeEncrypter) Decrypt(blob *EnvelopeBlob) ([]byte, error) {
+	dekValue, err := e.Provider.Unwrap(blob.KEKID, blob.WrappedDEK)
+	if err != nil {
+		return nil, fmt.Errorf("envelope: unable to unwrap data encryption key: %w", err)
+	}
+	dek := NewKey(dekValue)
+	if _, err := dek.aead(); err != nil {
+		return nil, fmt.Errorf("envelope: key provider returned an invalid data encryption key: %w", err)
+	}
+	return dek.Open(nil, blob.Nonce, blob.Ciphertext, blob.AAD)
+}
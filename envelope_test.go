@@ -0,0 +1,110 @@
+package tinycrypto
+
+import (
+	"bytes"
+	"context"
+	"testing"
+)
+
+func TestEnvelopeEncrypter_KeysetKeyProvider(t *testing.T) {
+	kek, _ := NewRandomKey()
+	ks := NewKeysetWithKey(kek)
+	enc := NewEnvelopeEncrypter(NewKeysetKeyProvider(ks))
+
+	plaintext := []byte("this is my secret value that I must protect")
+	aad := []byte("tenant-42")
+
+	blob, err := enc.Encrypt(plaintext, aad)
+	if err != nil {
+		t.Fatalf("the encryption process failed: %s", err.Error())
+	}
+	decrypted, err := enc.Decrypt(blob)
+	if err != nil {
+		t.Fatalf("the decryption process failed: %s", err.Error())
+	}
+	if !bytes.Equal(decrypted, plaintext) {
+		t.Fatalf("expected decrypted to be %q, but found %q", plaintext, decrypted)
+	}
+}
+
+func TestEnvelopeEncrypter_RotatingKEKStillDecryptsOldBlobs(t *testing.T) {
+	kek1, _ := NewRandomKey()
+	ks := NewKeysetWithKey(kek1)
+	enc := NewEnvelopeEncrypter(NewKeysetKeyProvider(ks))
+
+	plaintext := []byte("this is my secret value that I must protect")
+	blob, err := enc.Encrypt(plaintext, nil)
+	if err != nil {
+		t.Fatalf("the encryption process failed: %s", err.Error())
+	}
+
+	kek2, _ := NewRandomKey()
+	ks.RotateIn(kek2, 0)
+
+	decrypted, err := enc.Decrypt(blob)
+	if err != nil {
+		t.Fatalf("expected a blob wrapped under a rotated-out KEK to still decrypt: %s", err.Error())
+	}
+	if !bytes.Equal(decrypted, plaintext) {
+		t.Fatalf("expected decrypted to be %q, but found %q", plaintext, decrypted)
+	}
+}
+
+// fakeRemoteKeyProvider stands in for a real KMS adapter (AWS KMS, GCP KMS,
+// HashiCorp Vault, etc.), wrapping DEKs with a fixed local key.
+type fakeRemoteKeyProvider struct {
+	kek *Key
+}
+
+func (f *fakeRemoteKeyProvider) Wrap(ctx context.Context, dek []byte) (string, []byte, error) {
+	wrapped, err := EncryptWithKey(f.kek, dek, nil)
+	return "fake-kms-key-1", wrapped, err
+}
+
+func (f *fakeRemoteKeyProvider) Unwrap(ctx context.Context, kekID string, wrapped []byte) ([]byte, error) {
+	return DecryptWithKey(f.kek, wrapped, nil)
+}
+
+// badDEKKeyProvider stands in for a KMS backend that returns a corrupted or
+// malformed DEK, e.g. a truncated network response, rather than a provider
+// programming error.
+type badDEKKeyProvider struct{}
+
+func (badDEKKeyProvider) Wrap(dek []byte) (string, []byte, error) {
+	return "", dek, nil
+}
+
+func (badDEKKeyProvider) Unwrap(kekID string, wrapped []byte) ([]byte, error) {
+	return []byte("too-short"), nil
+}
+
+func TestEnvelopeEncrypter_Decrypt_InvalidDEKReturnsErrorNotPanic(t *testing.T) {
+	enc := NewEnvelopeEncrypter(badDEKKeyProvider{})
+	blob := &EnvelopeBlob{WrappedDEK: []byte("irrelevant"), Nonce: make([]byte, 12), Ciphertext: []byte("irrelevant")}
+
+	if _, err := enc.Decrypt(blob); err == nil {
+		t.Fatal("expected an invalid unwrapped DEK to produce an error, not a panic or success")
+	}
+}
+
+func TestEnvelopeEncrypter_RemoteKeyProviderAdapter(t *testing.T) {
+	kek, _ := NewRandomKey()
+	adapter := &RemoteKeyProviderAdapter{Provider: &fakeRemoteKeyProvider{kek: kek}}
+	enc := NewEnvelopeEncrypter(adapter)
+
+	plaintext := []byte("this is my secret value that I must protect")
+	blob, err := enc.Encrypt(plaintext, nil)
+	if err != nil {
+		t.Fatalf("the encryption process failed: %s", err.Error())
+	}
+	if blob.KEKID != "fake-kms-key-1" {
+		t.Fatalf("expected the blob to carry the remote provider's kek id, got %q", blob.KEKID)
+	}
+	decrypted, err := enc.Decrypt(blob)
+	if err != nil {
+		t.Fatalf("the decryption process failed: %s", err.Error())
+	}
+	if !bytes.Equal(decrypted, plaintext) {
+		t.Fatalf("expected decrypted to be %q, but found %q", plaintext, decrypted)
+	}
+}
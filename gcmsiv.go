@@ -0,0 +1,257 @@
+package tinycrypto
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/subtle"
+	"encoding/binary"
+	"errors"
+)
+
+// aesGCMSIV implements AES-256-GCM-SIV (RFC 8452), a nonce-misuse-resistant
+// AEAD: reusing a nonce with AES-GCM-SIV degrades gracefully (it reveals
+// only whether the same plaintext was sealed twice with the same nonce and
+// AAD) rather than catastrophically, the way it would with plain AES-GCM.
+//
+// This is a from-scratch, pure Go port of the generic (non-assembly)
+// algorithm description in github.com/secure-io/siv-go (MIT licensed,
+// (c) 2018 Andreas Auernhammer), since that package's amd64 assembly proved
+// incompatible with the current Go calling convention. It only supports
+// 32-byte (AES-256) keys, matching AlgAESGCMSIV.
+type aesGCMSIV struct {
+	block cipher.Block
+}
+
+var _ cipher.AEAD = (*aesGCMSIV)(nil)
+
+// newAESGCMSIV constructs an AES-256-GCM-SIV AEAD from a 32-byte key.
+func newAESGCMSIV(key []byte) (cipher.AEAD, error) {
+	if len(key) != 32 {
+		return nil, aes.KeySizeError(len(key))
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return &aesGCMSIV{block: block}, nil
+}
+
+func (c *aesGCMSIV) NonceSize() int { return 12 }
+
+func (c *aesGCMSIV) Overhead() int { return aes.BlockSize }
+
+func (c *aesGCMSIV) Seal(dst, nonce, plaintext, additionalData []byte) []byte {
+	if len(nonce) != c.NonceSize() {
+		panic("tinycrypto: incorrect nonce length given to AES-GCM-SIV")
+	}
+
+	ret, out := sliceForAppend(dst, len(plaintext)+c.Overhead())
+	ciphertext, tagOut := out[:len(plaintext)], out[len(plaintext):]
+
+	encKey, authKey := deriveGCMSIVKeys(nonce, c.block)
+
+	var tag [16]byte
+	polyval(&tag, authKey, additionalData, plaintext)
+	for i := range nonce {
+		tag[i] ^= nonce[i]
+	}
+	tag[15] &= 0x7f
+
+	encBlock, _ := aes.NewCipher(encKey)
+	encBlock.Encrypt(tag[:], tag[:])
+	copy(tagOut, tag[:])
+
+	ctrBlock := tag
+	ctrBlock[15] |= 0x80
+	gcmSIVXORKeyStream(ciphertext, plaintext, encBlock, ctrBlock[:])
+
+	return ret
+}
+
+func (c *aesGCMSIV) Open(dst, nonce, ciphertext, additionalData []byte) ([]byte, error) {
+	if len(nonce) != c.NonceSize() {
+		panic("tinycrypto: incorrect nonce length given to AES-GCM-SIV")
+	}
+	if len(ciphertext) < c.Overhead() {
+		return nil, errGCMSIVOpen
+	}
+
+	tag := ciphertext[len(ciphertext)-c.Overhead():]
+	ciphertext = ciphertext[:len(ciphertext)-c.Overhead()]
+
+	encKey, authKey := deriveGCMSIVKeys(nonce, c.block)
+	encBlock, _ := aes.NewCipher(encKey)
+
+	var ctrBlock [16]byte
+	copy(ctrBlock[:], tag)
+	ctrBlock[15] |= 0x80
+
+	ret, plaintext := sliceForAppend(dst, len(ciphertext))
+	gcmSIVXORKeyStream(plaintext, ciphertext, encBlock, ctrBlock[:])
+
+	var sum [16]byte
+	polyval(&sum, authKey, additionalData, plaintext)
+	for i := range nonce {
+		sum[i] ^= nonce[i]
+	}
+	sum[15] &= 0x7f
+	encBlock.Encrypt(sum[:], sum[:])
+
+	if subtle.ConstantTimeCompare(sum[:], tag) != 1 {
+		for i := range plaintext {
+			plaintext[i] = 0
+		}
+		return nil, errGCMSIVOpen
+	}
+	return ret, nil
+}
+
+// deriveGCMSIVKeys derives the per-nonce message-encryption key (32 bytes)
+// and message-authentication key (16 bytes) used by AES-256-GCM-SIV, per RFC
+// 8452 section 4.
+func deriveGCMSIVKeys(nonce []byte, block cipher.Block) (encKey, authKey []byte) {
+	var counter, tmp [16]byte
+	copy(counter[4:], nonce)
+
+	encKey = make([]byte, 32)
+	authKey = make([]byte, 16)
+
+	for i, dst := range [][]byte{authKey[0:8], authKey[8:16], encKey[0:8], encKey[8:16], encKey[16:24], encKey[24:32]} {
+		binary.LittleEndian.PutUint32(counter[:4], uint32(i))
+		block.Encrypt(tmp[:], counter[:])
+		copy(dst, tmp[:8])
+	}
+	return encKey, authKey
+}
+
+// gcmSIVXORKeyStream encrypts/decrypts src into dst with AES-CTR, using a
+// little-endian counter seeded from iv, as specified for AES-GCM-SIV.
+func gcmSIVXORKeyStream(dst, src []byte, block cipher.Block, iv []byte) {
+	var ctr, keystream [16]byte
+	copy(ctr[:], iv)
+	counter := binary.LittleEndian.Uint32(ctr[:4])
+	for len(src) >= 16 {
+		block.Encrypt(keystream[:], ctr[:])
+		for i := range keystream {
+			dst[i] = src[i] ^ keystream[i]
+		}
+		counter++
+		binary.LittleEndian.PutUint32(ctr[:4], counter)
+		dst, src = dst[16:], src[16:]
+	}
+	if len(src) > 0 {
+		block.Encrypt(keystream[:], ctr[:])
+		for i := range src {
+			dst[i] = src[i] ^ keystream[i]
+		}
+	}
+}
+
+// gcmSIVFieldElement is an element of GF(2^128), as used by POLYVAL.
+type gcmSIVFieldElement = [2]uint64
+
+// polyval computes the POLYVAL universal hash (RFC 8452 section 3) of
+// additionalData and plaintext (each zero-padded to a 16-byte boundary,
+// followed by their bit lengths) under authKey, writing the result to tag.
+func polyval(tag *[16]byte, authKey, additionalData, plaintext []byte) {
+	h := gcmSIVFieldElement{
+		binary.LittleEndian.Uint64(authKey[0:]),
+		binary.LittleEndian.Uint64(authKey[8:]),
+	}
+	var r gcmSIVFieldElement
+
+	absorb := func(data []byte) {
+		for len(data) >= 16 {
+			r[0] ^= binary.LittleEndian.Uint64(data)
+			r[1] ^= binary.LittleEndian.Uint64(data[8:])
+			gcmSIVMultiply(&r, &h)
+			data = data[16:]
+		}
+		if len(data) > 0 {
+			var buf [16]byte
+			copy(buf[:], data)
+			r[0] ^= binary.LittleEndian.Uint64(buf[0:])
+			r[1] ^= binary.LittleEndian.Uint64(buf[8:])
+			gcmSIVMultiply(&r, &h)
+		}
+	}
+	absorb(additionalData)
+	absorb(plaintext)
+
+	r[0] ^= 8 * uint64(len(additionalData))
+	r[1] ^= 8 * uint64(len(plaintext))
+	gcmSIVMultiply(&r, &h)
+
+	binary.LittleEndian.PutUint64(tag[0:], r[0])
+	binary.LittleEndian.PutUint64(tag[8:], r[1])
+}
+
+// gcmSIVMultiply multiplies r by h in POLYVAL's bit-reversed GF(2^128),
+// reducing by the POLYVAL polynomial, and stores the product back into r.
+func gcmSIVMultiply(r, h *gcmSIVFieldElement) {
+	const (
+		polyvalMask = 0xc200000000000000
+		lowMask     = 0x00000000ffffffff
+		highMask    = 0xffffffff00000000
+	)
+	t00, t01 := gcmSIVCarrylessMul(r[0], h[0])
+	t10, t11 := gcmSIVCarrylessMul(r[1], h[0])
+	t20, t21 := gcmSIVCarrylessMul(r[0], h[1])
+	t30, t31 := gcmSIVCarrylessMul(r[1], h[1])
+
+	t10 ^= t20
+	t11 ^= t21
+	t20 = 0
+	t21 = t10
+	t10 = t11
+	t11 = 0
+	t01 ^= t21
+	t30 ^= t10
+
+	t10, t11 = gcmSIVCarrylessMul(polyvalMask, t00)
+	t20 = (t01 & lowMask) | (t01 & highMask)
+	t21 = (t00 & lowMask) | (t00 & highMask)
+	t00 = t10 ^ t20
+	t01 = t11 ^ t21
+
+	t10, t11 = gcmSIVCarrylessMul(polyvalMask, t00)
+	t20 = (t01 & lowMask) | (t01 & highMask)
+	t21 = (t00 & lowMask) | (t00 & highMask)
+	t00 = t10 ^ t20
+	t01 = t11 ^ t21
+
+	r[0] = t30 ^ t00
+	r[1] = t31 ^ t01
+}
+
+// gcmSIVCarrylessMul is a 64x64-bit carryless (polynomial) multiplication,
+// producing a 128-bit product (d1:d0), in constant time.
+func gcmSIVCarrylessMul(x, y uint64) (d0, d1 uint64) {
+	const msb uint64 = 1 << 63
+	for i := uint(0); i < 64; i++ {
+		bit := uint64(0) - ((y >> i) & 1)
+		d1 ^= bit & x
+		d0 >>= 1
+		d0 ^= (uint64(0) - (d1 & 1)) & msb
+		d1 >>= 1
+	}
+	return d0, d1
+}
+
+// sliceForAppend takes a slice and a requested number of bytes. It returns a
+// slice with the contents of the given slice followed by that many bytes,
+// and a second slice that aliases into it and contains only the extra bytes.
+// If the original slice has sufficient capacity, no allocation is performed.
+func sliceForAppend(in []byte, n int) (head, tail []byte) {
+	if total := len(in) + n; cap(in) >= total {
+		head = in[:total]
+	} else {
+		head = make([]byte, total)
+		copy(head, in)
+	}
+	tail = head[len(in):]
+	return head, tail
+}
+
+// errGCMSIVOpen is returned when AES-GCM-SIV authentication fails.
+var errGCMSIVOpen = errors.New("tinycrypto: message authentication failed")
@@ -0,0 +1,120 @@
+package tinycrypto
+
+import (
+	"bytes"
+	"encoding/hex"
+	"testing"
+)
+
+// aesGCMSIVVector is a known-answer test vector for AES-256-GCM-SIV, taken
+// from the RFC 8452 reference test suite (the same AES-256-SIV-GCM vectors
+// published alongside the BoringSSL and MIT-licensed
+// github.com/secure-io/siv-go implementations). algorithm_test.go's
+// encrypt-then-decrypt round trip only proves gcmsiv.go is self-consistent;
+// these vectors catch a construction bug (wrong POLYVAL, wrong endianness,
+// etc.) that would still round-trip against itself.
+type aesGCMSIVVector struct {
+	key, plaintext, additionalData, nonce, ciphertext string
+}
+
+var aesGCMSIVVectors = []aesGCMSIVVector{
+	{
+		key:            "0100000000000000000000000000000000000000000000000000000000000000",
+		plaintext:      "",
+		additionalData: "",
+		nonce:          "030000000000000000000000",
+		ciphertext:     "07f5f4169bbf55a8400cd47ea6fd400f",
+	},
+	{
+		key:            "0100000000000000000000000000000000000000000000000000000000000000",
+		plaintext:      "0100000000000000",
+		additionalData: "",
+		nonce:          "030000000000000000000000",
+		ciphertext:     "c2ef328e5c71c83b843122130f7364b761e0b97427e3df28",
+	},
+	{
+		key:            "0100000000000000000000000000000000000000000000000000000000000000",
+		plaintext:      "010000000000000000000000",
+		additionalData: "",
+		nonce:          "030000000000000000000000",
+		ciphertext:     "9aab2aeb3faa0a34aea8e2b18ca50da9ae6559e48fd10f6e5c9ca17e",
+	},
+	{
+		key:            "0100000000000000000000000000000000000000000000000000000000000000",
+		plaintext:      "01000000000000000000000000000000",
+		additionalData: "",
+		nonce:          "030000000000000000000000",
+		ciphertext:     "85a01b63025ba19b7fd3ddfc033b3e76c9eac6fa700942702e90862383c6c366",
+	},
+	{
+		key:            "0100000000000000000000000000000000000000000000000000000000000000",
+		plaintext:      "0100000000000000000000000000000002000000000000000000000000000000",
+		additionalData: "",
+		nonce:          "030000000000000000000000",
+		ciphertext:     "4a6a9db4c8c6549201b9edb53006cba821ec9cf850948a7c86c68ac7539d027fe819e63abcd020b006a976397632eb5d",
+	},
+	{
+		key:            "0100000000000000000000000000000000000000000000000000000000000000",
+		plaintext:      "010000000000000000000000000000000200000000000000000000000000000003000000000000000000000000000000",
+		additionalData: "",
+		nonce:          "030000000000000000000000",
+		ciphertext:     "c00d121893a9fa603f48ccc1ca3c57ce7499245ea0046db16c53c7c66fe717e39cf6c748837b61f6ee3adcee17534ed5790bc96880a99ba804bd12c0e6a22cc4",
+	},
+	{
+		key:            "0100000000000000000000000000000000000000000000000000000000000000",
+		plaintext:      "01000000000000000000000000000000020000000000000000000000000000000300000000000000000000000000000004000000000000000000000000000000",
+		additionalData: "",
+		nonce:          "030000000000000000000000",
+		ciphertext:     "c2d5160a1f8683834910acdafc41fbb1632d4a353e8b905ec9a5499ac34f96c7e1049eb080883891a4db8caaa1f99dd004d80487540735234e3744512c6f90ce112864c269fc0d9d88c61fa47e39aa08",
+	},
+	{
+		key:            "3c535de192eaed3822a2fbbe2ca9dfc88255e14a661b8aa82cc54236093bbc23",
+		plaintext:      "ced532ce4159b035277d4dfbb7db62968b13cd4eec",
+		additionalData: "734320ccc9d9bbbb19cb81b2af4ecbc3e72834321f7aa0f70b7282b4f33df23f167541",
+		nonce:          "688089e55540db1872504e1c",
+		ciphertext:     "626660c26ea6612fb17ad91e8e767639edd6c9faee9d6c7029675b89eaf4ba1ded1a286594",
+	},
+}
+
+func TestAESGCMSIV_KnownAnswerVectors(t *testing.T) {
+	for i, v := range aesGCMSIVVectors {
+		key, err := hex.DecodeString(v.key)
+		if err != nil {
+			t.Fatalf("vector %d: bad key hex: %s", i, err.Error())
+		}
+		plaintext, err := hex.DecodeString(v.plaintext)
+		if err != nil {
+			t.Fatalf("vector %d: bad plaintext hex: %s", i, err.Error())
+		}
+		aad, err := hex.DecodeString(v.additionalData)
+		if err != nil {
+			t.Fatalf("vector %d: bad additionalData hex: %s", i, err.Error())
+		}
+		nonce, err := hex.DecodeString(v.nonce)
+		if err != nil {
+			t.Fatalf("vector %d: bad nonce hex: %s", i, err.Error())
+		}
+		wantCiphertext, err := hex.DecodeString(v.ciphertext)
+		if err != nil {
+			t.Fatalf("vector %d: bad ciphertext hex: %s", i, err.Error())
+		}
+
+		aead, err := newAESGCMSIV(key)
+		if err != nil {
+			t.Fatalf("vector %d: unable to construct AES-GCM-SIV: %s", i, err.Error())
+		}
+
+		gotCiphertext := aead.Seal(nil, nonce, plaintext, aad)
+		if !bytes.Equal(gotCiphertext, wantCiphertext) {
+			t.Fatalf("vector %d: Seal mismatch\n got: %x\nwant: %x", i, gotCiphertext, wantCiphertext)
+		}
+
+		gotPlaintext, err := aead.Open(nil, nonce, wantCiphertext, aad)
+		if err != nil {
+			t.Fatalf("vector %d: Open failed: %s", i, err.Error())
+		}
+		if !bytes.Equal(gotPlaintext, plaintext) {
+			t.Fatalf("vector %d: Open mismatch\n got: %x\nwant: %x", i, gotPlaintext, plaintext)
+		}
+	}
+}
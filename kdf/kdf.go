@@ -0,0 +1,246 @@
+// Package kdf derives cryptographic keys from passwords, for the cases where
+// the plain SHA-256 hash used by `tinycrypto.HashForString` is the wrong
+// tool: anything where the input is a password (short, low-entropy, and
+// possibly reused) rather than an already-random secret. It wraps scrypt and
+// Argon2id behind a single `DeriveKey` call and a self-describing encoded
+// hash format so stored hashes carry the parameters they were created with.
+package kdf
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/scrypt"
+)
+
+// Algorithm identifies which KDF produced a derived key.
+type Algorithm string
+
+// Supported key derivation algorithms.
+const (
+	AlgScrypt   Algorithm = "scrypt"
+	AlgArgon2id Algorithm = "argon2id"
+)
+
+// Params configures a key derivation. Use DefaultScryptParams,
+// DefaultArgon2idParams, or CalibrateParams to get sensible values rather
+// than constructing a Params by hand.
+type Params struct {
+	Algorithm Algorithm
+
+	// N, R, P configure scrypt. Ignored for other algorithms.
+	N, R, P int
+
+	// Time, Memory (KiB), and Threads configure Argon2id. Ignored for other
+	// algorithms.
+	Time, Memory uint32
+	Threads      uint8
+
+	// KeyLen is the length, in bytes, of the derived key.
+	KeyLen int
+
+	// SaltLen is the length, in bytes, of salt GenerateFromPassword should
+	// generate. Ignored by DeriveKey, which takes the salt to use directly.
+	SaltLen int
+}
+
+// DefaultScryptParams returns scrypt parameters with a sensible work factor
+// for interactive login (N=32768, r=8, p=1), deriving a 32-byte key from a
+// 16-byte salt.
+func DefaultScryptParams() Params {
+	return Params{Algorithm: AlgScrypt, N: 32768, R: 8, P: 1, KeyLen: 32, SaltLen: 16}
+}
+
+// DefaultArgon2idParams returns Argon2id parameters following the RFC draft's
+// non-interactive recommendation (time=1, memory=64MiB, threads=4), deriving
+// a 32-byte key from a 16-byte salt.
+func DefaultArgon2idParams() Params {
+	return Params{Algorithm: AlgArgon2id, Time: 1, Memory: 64 * 1024, Threads: 4, KeyLen: 32, SaltLen: 16}
+}
+
+// DeriveKey derives a key of params.KeyLen bytes from password and salt,
+// using the algorithm and cost parameters in params.
+func DeriveKey(password, salt []byte, params Params) ([]byte, error) {
+	switch params.Algorithm {
+	case AlgScrypt:
+		return scrypt.Key(password, salt, params.N, params.R, params.P, params.KeyLen)
+	case AlgArgon2id:
+		return argon2.IDKey(password, salt, params.Time, params.Memory, params.Threads, uint32(params.KeyLen)), nil
+	default:
+		return nil, fmt.Errorf("kdf: unsupported algorithm %q", params.Algorithm)
+	}
+}
+
+// GenerateFromPassword derives a key from password, using DefaultScryptParams
+// and a fresh random salt, and returns a self-describing encoded string
+// containing the algorithm, parameters, salt, and derived key, e.g.
+// "scrypt$N=32768,r=8,p=1$<b64salt>$<b64dk>". Persist the returned string and
+// pass it to CompareHashAndPassword to verify future login attempts.
+func GenerateFromPassword(password []byte) (encoded []byte, err error) {
+	return GenerateFromPasswordWithParams(password, DefaultScryptParams())
+}
+
+// GenerateFromPasswordWithParams is GenerateFromPassword with explicit
+// params, e.g. the output of CalibrateParams, or DefaultArgon2idParams.
+func GenerateFromPasswordWithParams(password []byte, params Params) (encoded []byte, err error) {
+	salt := make([]byte, params.SaltLen)
+	if _, err := io.ReadFull(rand.Reader, salt); err != nil {
+		return nil, fmt.Errorf("kdf: unable to generate salt: %w", err)
+	}
+	dk, err := DeriveKey(password, salt, params)
+	if err != nil {
+		return nil, err
+	}
+	return []byte(encode(params, salt, dk)), nil
+}
+
+// CompareHashAndPassword re-derives a key from password, using the algorithm
+// and parameters embedded in encoded, and reports whether it matches the
+// derived key embedded in encoded. It returns a non-nil error both when the
+// password doesn't match and when encoded can't be parsed.
+//
+// Callers can compare the params embedded in encoded against their current
+// defaults (or the output of CalibrateParams) to decide whether to rehash on
+// successful login.
+func CompareHashAndPassword(encoded, password []byte) error {
+	params, salt, dk, err := decode(string(encoded))
+	if err != nil {
+		return err
+	}
+	candidate, err := DeriveKey(password, salt, params)
+	if err != nil {
+		return err
+	}
+	if subtle.ConstantTimeCompare(candidate, dk) != 1 {
+		return errors.New("kdf: password does not match")
+	}
+	return nil
+}
+
+// minScryptN is the lowest N CalibrateParams will shrink down to. Below
+// this, scrypt stops meaningfully slowing down a brute-force attacker, so a
+// target time budget that can't be met even here is treated as unreachable
+// and CalibrateParams settles for the floor rather than going lower.
+const minScryptN = 1024
+
+// CalibrateParams benchmarks the host and returns scrypt parameters whose
+// derivation takes as close to target as possible. Starting from
+// DefaultScryptParams, it halves N while a measured derivation takes longer
+// than target, stopping at minScryptN (whose cost may still exceed target),
+// then doubles N while twice the last measured time still fits within
+// target. Use the result with GenerateFromPasswordWithParams to auto-tune
+// the work factor for a given deployment rather than hard-coding a work
+// factor that may be too slow on small hosts or too fast on large ones.
+func CalibrateParams(target time.Duration) (Params, error) {
+	params := DefaultScryptParams()
+	probeSalt := make([]byte, params.SaltLen)
+
+	measure := func() (time.Duration, error) {
+		start := time.Now()
+		if _, err := scrypt.Key([]byte("tinycrypto-kdf-calibration"), probeSalt, params.N, params.R, params.P, params.KeyLen); err != nil {
+			return 0, err
+		}
+		return time.Since(start), nil
+	}
+
+	elapsed, err := measure()
+	if err != nil {
+		return Params{}, err
+	}
+
+	// DefaultScryptParams (N=32768) may already overshoot target on a slow
+	// or loaded host, or a small target. Shrink N until it fits, rather than
+	// returning parameters that blow the caller's time budget.
+	for elapsed > target && params.N > minScryptN {
+		params.N /= 2
+		if elapsed, err = measure(); err != nil {
+			return Params{}, err
+		}
+	}
+
+	for elapsed*2 <= target {
+		params.N *= 2
+		if elapsed, err = measure(); err != nil {
+			return Params{}, err
+		}
+	}
+	return params, nil
+}
+
+// encode renders params, salt, and dk as a single self-describing string of
+// the form "<algorithm>$<params>$<b64 salt>$<b64 dk>".
+func encode(params Params, salt, dk []byte) string {
+	var paramStr string
+	switch params.Algorithm {
+	case AlgArgon2id:
+		paramStr = fmt.Sprintf("t=%d,m=%d,p=%d", params.Time, params.Memory, params.Threads)
+	default:
+		paramStr = fmt.Sprintf("N=%d,r=%d,p=%d", params.N, params.R, params.P)
+	}
+	return fmt.Sprintf(
+		"%s$%s$%s$%s",
+		params.Algorithm,
+		paramStr,
+		base64.RawStdEncoding.EncodeToString(salt),
+		base64.RawStdEncoding.EncodeToString(dk),
+	)
+}
+
+// decode parses a string produced by encode back into its params, salt, and
+// derived key.
+func decode(encoded string) (params Params, salt, dk []byte, err error) {
+	parts := strings.Split(encoded, "$")
+	if len(parts) != 4 {
+		return Params{}, nil, nil, errors.New("kdf: malformed encoded hash")
+	}
+	alg := Algorithm(parts[0])
+
+	params, err = parseParams(alg, parts[1])
+	if err != nil {
+		return Params{}, nil, nil, err
+	}
+	salt, err = base64.RawStdEncoding.DecodeString(parts[2])
+	if err != nil {
+		return Params{}, nil, nil, fmt.Errorf("kdf: malformed salt: %w", err)
+	}
+	dk, err = base64.RawStdEncoding.DecodeString(parts[3])
+	if err != nil {
+		return Params{}, nil, nil, fmt.Errorf("kdf: malformed derived key: %w", err)
+	}
+	params.KeyLen = len(dk)
+	return params, salt, dk, nil
+}
+
+// parseParams parses the comma-separated "k=v" parameter segment of an
+// encoded hash for the given algorithm.
+func parseParams(alg Algorithm, s string) (Params, error) {
+	kv := map[string]int{}
+	for _, field := range strings.Split(s, ",") {
+		k, v, ok := strings.Cut(field, "=")
+		if !ok {
+			return Params{}, fmt.Errorf("kdf: malformed parameter %q", field)
+		}
+		n, err := strconv.Atoi(v)
+		if err != nil {
+			return Params{}, fmt.Errorf("kdf: malformed parameter %q: %w", field, err)
+		}
+		kv[k] = n
+	}
+
+	switch alg {
+	case AlgScrypt:
+		return Params{Algorithm: AlgScrypt, N: kv["N"], R: kv["r"], P: kv["p"]}, nil
+	case AlgArgon2id:
+		return Params{Algorithm: AlgArgon2id, Time: uint32(kv["t"]), Memory: uint32(kv["m"]), Threads: uint8(kv["p"])}, nil
+	default:
+		return Params{}, fmt.Errorf("kdf: unsupported algorithm %q", alg)
+	}
+}
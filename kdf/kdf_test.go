@@ -0,0 +1,84 @@
+package kdf
+
+import (
+	"bytes"
+	"testing"
+	"time"
+)
+
+func TestDeriveKey_Deterministic(t *testing.T) {
+	password := []byte("hunter2")
+	salt := []byte("0123456789abcdef")
+	params := DefaultScryptParams()
+
+	dk1, err := DeriveKey(password, salt, params)
+	if err != nil {
+		t.Fatalf("the derivation process failed: %s", err.Error())
+	}
+	dk2, err := DeriveKey(password, salt, params)
+	if err != nil {
+		t.Fatalf("the derivation process failed: %s", err.Error())
+	}
+	if !bytes.Equal(dk1, dk2) {
+		t.Fatal("expected DeriveKey to be deterministic for the same password, salt, and params")
+	}
+	if len(dk1) != params.KeyLen {
+		t.Fatalf("expected a %d-byte key, got %d bytes", params.KeyLen, len(dk1))
+	}
+}
+
+func TestGenerateFromPasswordCompareHashAndPassword(t *testing.T) {
+	password := []byte("correct horse battery staple")
+
+	encoded, err := GenerateFromPassword(password)
+	if err != nil {
+		t.Fatalf("the generation process failed: %s", err.Error())
+	}
+	if err := CompareHashAndPassword(encoded, password); err != nil {
+		t.Fatalf("expected the correct password to match, got error: %s", err.Error())
+	}
+	if err := CompareHashAndPassword(encoded, []byte("wrong password")); err == nil {
+		t.Fatal("expected the wrong password to be rejected, got nil error")
+	}
+}
+
+func TestGenerateFromPasswordWithParams_Argon2id(t *testing.T) {
+	password := []byte("correct horse battery staple")
+	params := DefaultArgon2idParams()
+
+	encoded, err := GenerateFromPasswordWithParams(password, params)
+	if err != nil {
+		t.Fatalf("the generation process failed: %s", err.Error())
+	}
+	if err := CompareHashAndPassword(encoded, password); err != nil {
+		t.Fatalf("expected the correct password to match, got error: %s", err.Error())
+	}
+}
+
+func TestCalibrateParams(t *testing.T) {
+	// A generous target gives CalibrateParams room to grow N above the
+	// default on any host, fast or slow.
+	params, err := CalibrateParams(2 * time.Second)
+	if err != nil {
+		t.Fatalf("the calibration process failed: %s", err.Error())
+	}
+	if params.N < DefaultScryptParams().N {
+		t.Fatalf("expected calibrated N to be at least the default, got %d", params.N)
+	}
+}
+
+func TestCalibrateParams_ShrinksBelowDefaultWhenDefaultOvershootsTarget(t *testing.T) {
+	// A target far below what DefaultScryptParams() costs (N=32768) forces
+	// CalibrateParams to shrink N, rather than returning a default that
+	// blows the caller's time budget.
+	params, err := CalibrateParams(time.Nanosecond)
+	if err != nil {
+		t.Fatalf("the calibration process failed: %s", err.Error())
+	}
+	if params.N >= DefaultScryptParams().N {
+		t.Fatalf("expected calibrated N to shrink below the default, got %d", params.N)
+	}
+	if params.N < minScryptN {
+		t.Fatalf("expected calibrated N to not go below the floor %d, got %d", minScryptN, params.N)
+	}
+}
@@ -0,0 +1,93 @@
+package tinycrypto
+
+import (
+	"crypto/sha256"
+	"errors"
+	"fmt"
+)
+
+// keyIDInfo is appended to a Key's Value before hashing, so a KeyID can't be
+// reused as, or confused with, a hash of the raw key value for any other
+// purpose.
+const keyIDInfo = "tinycrypto-keyid-v1"
+
+// KeyID identifies a Key by the first 8 bytes of SHA-256(Value || "tinycrypto-keyid-v1").
+// It's deterministic (the same Value always yields the same KeyID), so it
+// can be stored in a ciphertext header and used to look the right key back
+// up in a Keyset (see KeyByID), instead of trial-decrypting against every key.
+type KeyID [8]byte
+
+// ID returns k's KeyID, derived from its Value.
+func (k *Key) ID() KeyID {
+	h := sha256.New()
+	h.Write(k.Value)
+	h.Write([]byte(keyIDInfo))
+	sum := h.Sum(nil)
+	var id KeyID
+	copy(id[:], sum[:len(id)])
+	return id
+}
+
+// KeyByID returns the key in ks whose ID is id, or nil if there isn't one.
+// It scans the keys in order, hashing each one's Value, so its cost grows
+// with the number of keys; it performs no decryption.
+func (ks *Keyset) KeyByID(id KeyID) *Key {
+	ks.RLock()
+	defer ks.RUnlock()
+	for _, k := range ks.keys {
+		if k.ID() == id {
+			return k
+		}
+	}
+	return nil
+}
+
+// cipherTextVersionWithKeyID marks a ciphertext produced by sealWithKeyID:
+// version, KeyID, algorithm, nonce, then the sealed data. Keyset.Decrypt
+// recognizes it and looks the key up by ID instead of trial-decrypting.
+const cipherTextVersionWithKeyID byte = 2
+
+// sealWithKeyID seals val (authenticating aad) under key, prefixing the
+// ciphertext with key's ID so the right key can be found by ID on the way
+// back in.
+func sealWithKeyID(key *Key, val, aad []byte) ([]byte, error) {
+	nonce, err := key.NewRandomNonce()
+	if err != nil {
+		return nil, err
+	}
+	id := key.ID()
+	out := make([]byte, 0, 1+len(id)+1+len(nonce)+len(val)+key.Overhead())
+	out = append(out, cipherTextVersionWithKeyID)
+	out = append(out, id[:]...)
+	out = append(out, byte(key.Algorithm))
+	out = append(out, nonce...)
+	return key.Seal(out, nonce, val, aad), nil
+}
+
+// openWithKeyID decrypts a ciphertext produced by sealWithKeyID, looking its
+// key up in ks by the ID embedded in the ciphertext.
+func openWithKeyID(ks *Keyset, val, aad []byte) ([]byte, error) {
+	const headerLen = 1 + 8 + 1 // version + KeyID + algorithm
+	if len(val) < headerLen {
+		return nil, errors.New("tinycrypto: the cipher text value is too short")
+	}
+	var id KeyID
+	copy(id[:], val[1:9])
+	alg := Algorithm(val[9])
+
+	key := ks.KeyByID(id)
+	if key == nil {
+		return nil, fmt.Errorf("tinycrypto: no keyset key with id %x", id)
+	}
+	if key.Algorithm != alg {
+		return nil, fmt.Errorf("tinycrypto: ciphertext algorithm %d does not match key algorithm %d", alg, key.Algorithm)
+	}
+
+	rest := val[headerLen:]
+	nonceSize := key.NonceSize()
+	if len(rest) < nonceSize {
+		return nil, errors.New("tinycrypto: the cipher text value is too short")
+	}
+	nonce, ciphertext := rest[:nonceSize], rest[nonceSize:]
+	return key.Open(nil, nonce, ciphertext, aad)
+}
@@ -0,0 +1,108 @@
+package tinycrypto
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestKey_ID_IsStableAndDistinct(t *testing.T) {
+	key1, _ := NewRandomKey()
+	key2, _ := NewRandomKey()
+
+	if key1.ID() != key1.ID() {
+		t.Fatal("expected a key's ID to be stable across calls")
+	}
+	if key1.ID() == key2.ID() {
+		t.Fatal("expected distinct keys to have distinct IDs")
+	}
+}
+
+func TestKeyset_KeyByID(t *testing.T) {
+	key1, _ := NewRandomKey()
+	key2, _ := NewRandomKey()
+	ks := NewKeysetWithKey(key1)
+	ks.RotateIn(key2, 0)
+
+	if got := ks.KeyByID(key1.ID()); got != key1 {
+		t.Fatal("expected KeyByID to find the rotated-out key")
+	}
+	if got := ks.KeyByID(key2.ID()); got != key2 {
+		t.Fatal("expected KeyByID to find the current key")
+	}
+
+	var bogus KeyID
+	if got := ks.KeyByID(bogus); got != nil {
+		t.Fatal("expected KeyByID to return nil for an unknown id")
+	}
+}
+
+func TestKeyset_EncryptDecrypt_PicksRightKeyByID(t *testing.T) {
+	oldKey, _ := NewRandomKey()
+	ks := NewKeysetWithKey(oldKey)
+
+	plaintext := []byte("this is my secret value that I must protect")
+	encrypted, err := ks.Encrypt(plaintext)
+	if err != nil {
+		t.Fatalf("the encryption process failed: %s", err.Error())
+	}
+	if encrypted[0] != cipherTextVersionWithKeyID {
+		t.Fatalf("expected ciphertext version %d, got %d", cipherTextVersionWithKeyID, encrypted[0])
+	}
+
+	newKey, _ := NewRandomKey()
+	ks.RotateIn(newKey, 0) // oldKey is still present, just not first
+
+	decrypted, err := ks.Decrypt(encrypted)
+	if err != nil {
+		t.Fatalf("the decryption process failed: %s", err.Error())
+	}
+	if !bytes.Equal(decrypted, plaintext) {
+		t.Fatalf("expected decrypted to be %q, but found %q", plaintext, decrypted)
+	}
+}
+
+func TestKeyset_Decrypt_FallsBackToLegacyFormats(t *testing.T) {
+	key, _ := NewRandomKey()
+	ks := NewKeysetWithKey(key)
+	plaintext := []byte("this is my secret value that I must protect")
+
+	v1, err := EncryptWithKey(key, plaintext, nil)
+	if err != nil {
+		t.Fatalf("unable to produce a v1 ciphertext: %s", err.Error())
+	}
+	decrypted, err := ks.Decrypt(v1)
+	if err != nil {
+		t.Fatalf("expected Keyset.Decrypt to fall back to the v1 format: %s", err.Error())
+	}
+	if !bytes.Equal(decrypted, plaintext) {
+		t.Fatalf("expected decrypted to be %q, but found %q", plaintext, decrypted)
+	}
+
+	legacy, err := Encrypt(plaintext, key.Value)
+	if err != nil {
+		t.Fatalf("unable to produce a legacy ciphertext: %s", err.Error())
+	}
+	decrypted, err = ks.Decrypt(legacy)
+	if err != nil {
+		t.Fatalf("expected Keyset.Decrypt to fall back to the legacy format: %s", err.Error())
+	}
+	if !bytes.Equal(decrypted, plaintext) {
+		t.Fatalf("expected decrypted to be %q, but found %q", plaintext, decrypted)
+	}
+}
+
+func TestOpenWithKeyID_UnknownKeyID(t *testing.T) {
+	key, _ := NewRandomKey()
+	ks := NewKeysetWithKey(key)
+	val, err := sealWithKeyID(key, []byte("secret"), nil)
+	if err != nil {
+		t.Fatalf("unable to seal: %s", err.Error())
+	}
+
+	otherKey, _ := NewRandomKey()
+	otherKS := NewKeysetWithKey(otherKey)
+	if _, err := openWithKeyID(otherKS, val, nil); err == nil {
+		t.Fatal("expected opening with an unrelated keyset to fail")
+	}
+	_ = ks
+}
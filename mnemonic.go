@@ -0,0 +1,114 @@
+package tinycrypto
+
+import (
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+)
+
+// mnemonicWordCount is the number of words in a tinycrypto mnemonic phrase:
+// 24 words * 11 bits/word = 264 bits, split into a 256-bit Key value and an
+// 8-bit checksum, matching the BIP39 construction for a 256-bit entropy
+// input.
+const mnemonicWordCount = 24
+
+// wordIndex maps each word in englishWordlist to its position, built once so
+// KeyFromMnemonic and ValidateMnemonic don't do a linear scan per word.
+var wordIndex = func() map[string]uint16 {
+	m := make(map[string]uint16, len(englishWordlist))
+	for i, w := range englishWordlist {
+		m[w] = uint16(i)
+	}
+	return m
+}()
+
+// KeyToMnemonic encodes k's 32-byte Value as a 24-word BIP39-style phrase
+// over englishWordlist, so operators have a human-transcribable way to back
+// up and restore the root key protecting a Keyset, instead of copying out
+// hex or base64.
+func KeyToMnemonic(k *Key) (string, error) {
+	if len(k.Value) != 32 {
+		return "", fmt.Errorf("tinycrypto: mnemonic encoding requires a 32-byte key, got %d bytes", len(k.Value))
+	}
+
+	checksum := sha256.Sum256(k.Value)
+	bits := new(big.Int).SetBytes(k.Value)
+	bits.Lsh(bits, 8)
+	bits.Or(bits, big.NewInt(int64(checksum[0])))
+
+	words := make([]string, mnemonicWordCount)
+	mask := big.NewInt(1<<11 - 1)
+	for i := mnemonicWordCount - 1; i >= 0; i-- {
+		idx := new(big.Int).And(bits, mask)
+		words[i] = englishWordlist[idx.Uint64()]
+		bits.Rsh(bits, 11)
+	}
+	return strings.Join(words, " "), nil
+}
+
+// KeyFromMnemonic decodes a phrase produced by KeyToMnemonic (or NewMnemonic)
+// back into a Key, verifying its checksum word.
+func KeyFromMnemonic(words string) (*Key, error) {
+	bits, err := mnemonicBits(words)
+	if err != nil {
+		return nil, err
+	}
+
+	checksum := byte(new(big.Int).And(bits, big.NewInt(0xFF)).Uint64())
+	value := new(big.Int).Rsh(bits, 8).Bytes()
+	// big.Int.Bytes drops leading zero bytes; pad back out to 32.
+	padded := make([]byte, 32)
+	copy(padded[32-len(value):], value)
+
+	want := sha256.Sum256(padded)
+	if checksum != want[0] {
+		return nil, errors.New("tinycrypto: mnemonic checksum does not match")
+	}
+	return NewKey(padded), nil
+}
+
+// ValidateMnemonic reports whether words is a well-formed phrase produced by
+// KeyToMnemonic: the right word count, every word present in
+// englishWordlist, and a matching checksum. It's meant for validating
+// operator input in a UI before attempting to use the phrase as a key.
+func ValidateMnemonic(words string) error {
+	_, err := KeyFromMnemonic(words)
+	return err
+}
+
+// NewMnemonic generates a fresh random 256-bit Key and its mnemonic phrase in
+// one call.
+func NewMnemonic() (*Key, string, error) {
+	k, err := NewRandomKey()
+	if err != nil {
+		return nil, "", err
+	}
+	phrase, err := KeyToMnemonic(k)
+	if err != nil {
+		return nil, "", err
+	}
+	return k, phrase, nil
+}
+
+// mnemonicBits validates and decodes a phrase's words into the combined
+// 264-bit value (256-bit key value followed by an 8-bit checksum) that
+// KeyToMnemonic encoded.
+func mnemonicBits(words string) (*big.Int, error) {
+	fields := strings.Fields(words)
+	if len(fields) != mnemonicWordCount {
+		return nil, fmt.Errorf("tinycrypto: expected %d mnemonic words, got %d", mnemonicWordCount, len(fields))
+	}
+
+	bits := new(big.Int)
+	for _, w := range fields {
+		idx, ok := wordIndex[strings.ToLower(w)]
+		if !ok {
+			return nil, fmt.Errorf("tinycrypto: %q is not in the mnemonic wordlist", w)
+		}
+		bits.Lsh(bits, 11)
+		bits.Or(bits, big.NewInt(int64(idx)))
+	}
+	return bits, nil
+}
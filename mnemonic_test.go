@@ -0,0 +1,81 @@
+package tinycrypto
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestKeyToMnemonicKeyFromMnemonic_RoundTrip(t *testing.T) {
+	key, _ := NewRandomKey()
+	phrase, err := KeyToMnemonic(key)
+	if err != nil {
+		t.Fatalf("unable to encode mnemonic: %s", err.Error())
+	}
+	if got := len(strings.Fields(phrase)); got != mnemonicWordCount {
+		t.Fatalf("expected a %d-word phrase, got %d words", mnemonicWordCount, got)
+	}
+
+	restored, err := KeyFromMnemonic(phrase)
+	if err != nil {
+		t.Fatalf("unable to decode mnemonic: %s", err.Error())
+	}
+	if !bytes.Equal(restored.Value, key.Value) {
+		t.Fatal("expected the restored key's value to match the original")
+	}
+}
+
+func TestNewMnemonic(t *testing.T) {
+	key, phrase, err := NewMnemonic()
+	if err != nil {
+		t.Fatalf("unable to generate a new mnemonic: %s", err.Error())
+	}
+	if err := ValidateMnemonic(phrase); err != nil {
+		t.Fatalf("expected a freshly generated phrase to validate: %s", err.Error())
+	}
+	restored, err := KeyFromMnemonic(phrase)
+	if err != nil {
+		t.Fatalf("unable to decode mnemonic: %s", err.Error())
+	}
+	if !bytes.Equal(restored.Value, key.Value) {
+		t.Fatal("expected the restored key's value to match the generated key")
+	}
+}
+
+func TestValidateMnemonic_RejectsWrongWordCount(t *testing.T) {
+	if err := ValidateMnemonic("abandon ability able"); err == nil {
+		t.Fatal("expected a short phrase to fail validation")
+	}
+}
+
+func TestValidateMnemonic_RejectsUnknownWord(t *testing.T) {
+	_, phrase, err := NewMnemonic()
+	if err != nil {
+		t.Fatalf("unable to generate a new mnemonic: %s", err.Error())
+	}
+	words := strings.Fields(phrase)
+	words[0] = "notarealbip39word"
+	if err := ValidateMnemonic(strings.Join(words, " ")); err == nil {
+		t.Fatal("expected a phrase with an unknown word to fail validation")
+	}
+}
+
+func TestValidateMnemonic_RejectsBadChecksum(t *testing.T) {
+	_, phrase, err := NewMnemonic()
+	if err != nil {
+		t.Fatalf("unable to generate a new mnemonic: %s", err.Error())
+	}
+	words := strings.Fields(phrase)
+	// Swap the last word for a different one, which almost certainly breaks
+	// the checksum without changing the word count or introducing an unknown
+	// word.
+	last := words[len(words)-1]
+	replacement := "zoo"
+	if last == replacement {
+		replacement = "zebra"
+	}
+	words[len(words)-1] = replacement
+	if err := ValidateMnemonic(strings.Join(words, " ")); err == nil {
+		t.Fatal("expected a phrase with a broken checksum to fail validation")
+	}
+}
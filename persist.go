@@ -0,0 +1,167 @@
+package tinycrypto
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+)
+
+// keysetMagic identifies the wire format produced by Keyset.MarshalBinary, so
+// UnmarshalBinary can reject data that isn't a marshaled Keyset before it
+// gets any further.
+var keysetMagic = [4]byte{'T', 'C', 'K', 'S'}
+
+// keysetWireVersion is the version of the Keyset wire format. Bump this, and
+// branch on it in UnmarshalBinary, if the layout ever needs to change.
+const keysetWireVersion byte = 1
+
+// MarshalBinary encodes ks as a versioned, self-describing blob: magic,
+// version, TypeID, then each key's ID, Algorithm, CreatedUnix, ExpiresUnix,
+// and Value. The KeyID is stored alongside each key (rather than
+// recomputed lazily) so a corrupted Value is caught by UnmarshalBinary
+// instead of surfacing later as a silent KeyByID lookup miss.
+func (ks *Keyset) MarshalBinary() ([]byte, error) {
+	ks.RLock()
+	defer ks.RUnlock()
+
+	if len(ks.keys) > 1<<16-1 {
+		return nil, fmt.Errorf("tinycrypto: keyset has too many keys to marshal (%d)", len(ks.keys))
+	}
+
+	var buf bytes.Buffer
+	buf.Write(keysetMagic[:])
+	buf.WriteByte(keysetWireVersion)
+	_ = binary.Write(&buf, binary.BigEndian, int32(ks.TypeID))
+	_ = binary.Write(&buf, binary.BigEndian, uint16(len(ks.keys)))
+
+	for _, k := range ks.keys {
+		if len(k.Value) > 1<<16-1 {
+			return nil, fmt.Errorf("tinycrypto: key value too large to marshal (%d bytes)", len(k.Value))
+		}
+		id := k.ID()
+		buf.Write(id[:])
+		buf.WriteByte(byte(k.Algorithm))
+		_ = binary.Write(&buf, binary.BigEndian, k.CreatedUnix)
+		_ = binary.Write(&buf, binary.BigEndian, k.ExpiresUnix)
+		_ = binary.Write(&buf, binary.BigEndian, uint16(len(k.Value)))
+		buf.Write(k.Value)
+	}
+	return buf.Bytes(), nil
+}
+
+// UnmarshalBinary decodes a blob produced by MarshalBinary into ks, replacing
+// any keys it already holds. It recomputes each key's ID from its decoded
+// Value and rejects the blob if that doesn't match the stored ID, catching
+// truncation or corruption that a plain length check would miss.
+func (ks *Keyset) UnmarshalBinary(data []byte) error {
+	if len(data) < len(keysetMagic)+1 || !bytes.Equal(data[:len(keysetMagic)], keysetMagic[:]) {
+		return fmt.Errorf("tinycrypto: not a marshaled keyset")
+	}
+	r := bytes.NewReader(data[len(keysetMagic):])
+
+	version, err := r.ReadByte()
+	if err != nil {
+		return fmt.Errorf("tinycrypto: truncated keyset: %w", err)
+	}
+	if version != keysetWireVersion {
+		return fmt.Errorf("tinycrypto: unsupported keyset wire version %d", version)
+	}
+
+	var typeID int32
+	if err := binary.Read(r, binary.BigEndian, &typeID); err != nil {
+		return fmt.Errorf("tinycrypto: truncated keyset: %w", err)
+	}
+	var count uint16
+	if err := binary.Read(r, binary.BigEndian, &count); err != nil {
+		return fmt.Errorf("tinycrypto: truncated keyset: %w", err)
+	}
+
+	keys := make([]*Key, 0, count)
+	for i := uint16(0); i < count; i++ {
+		var wantID KeyID
+		if _, err := io.ReadFull(r, wantID[:]); err != nil {
+			return fmt.Errorf("tinycrypto: truncated keyset: %w", err)
+		}
+		alg, err := r.ReadByte()
+		if err != nil {
+			return fmt.Errorf("tinycrypto: truncated keyset: %w", err)
+		}
+		k := &Key{Algorithm: Algorithm(alg)}
+		if err := binary.Read(r, binary.BigEndian, &k.CreatedUnix); err != nil {
+			return fmt.Errorf("tinycrypto: truncated keyset: %w", err)
+		}
+		if err := binary.Read(r, binary.BigEndian, &k.ExpiresUnix); err != nil {
+			return fmt.Errorf("tinycrypto: truncated keyset: %w", err)
+		}
+		var valueLen uint16
+		if err := binary.Read(r, binary.BigEndian, &valueLen); err != nil {
+			return fmt.Errorf("tinycrypto: truncated keyset: %w", err)
+		}
+		k.Value = make([]byte, valueLen)
+		if _, err := io.ReadFull(r, k.Value); err != nil {
+			return fmt.Errorf("tinycrypto: truncated keyset: %w", err)
+		}
+		if k.ID() != wantID {
+			return fmt.Errorf("tinycrypto: key %d fails its integrity check: computed id does not match stored id", i)
+		}
+		keys = append(keys, k)
+	}
+
+	ks.Lock()
+	defer ks.Unlock()
+	ks.TypeID = int(typeID)
+	ks.keys = keys
+	return nil
+}
+
+// SealedKeyset wraps a CryptoKeyStore so that Keysets are actually encrypted
+// at rest under a master Key before they reach the store, and decrypted
+// again on the way out. This enforces, in the library, the "encrypted at
+// rest" contract that CryptoKeyStore's doc comment only describes.
+type SealedKeyset struct {
+	Store  CryptoKeyStore
+	Master *Key
+}
+
+// NewSealedKeyset constructs a SealedKeyset that encrypts keysets under
+// master before handing them to store, and decrypts them again on Get.
+func NewSealedKeyset(store CryptoKeyStore, master *Key) *SealedKeyset {
+	return &SealedKeyset{Store: store, Master: master}
+}
+
+// Put marshals keyset, seals it under sk.Master, and hands the result to
+// sk.Store wrapped in a single-key Keyset, so CryptoKeyStore implementors
+// never see plaintext key material.
+func (sk *SealedKeyset) Put(name string, keyset *Keyset) error {
+	plaintext, err := keyset.MarshalBinary()
+	if err != nil {
+		return fmt.Errorf("unable to marshal keyset %q: %w", name, err)
+	}
+	sealed, err := EncryptWithKey(sk.Master, plaintext, nil)
+	if err != nil {
+		return fmt.Errorf("unable to seal keyset %q: %w", name, err)
+	}
+	return sk.Store.PutCryptoKeyset(name, NewKeysetWithKey(&Key{Value: sealed}))
+}
+
+// Get fetches the sealed keyset named name from sk.Store, decrypts it under
+// sk.Master, and unmarshals the result.
+func (sk *SealedKeyset) Get(name string) (*Keyset, error) {
+	wrapper, err := sk.Store.GetCryptoKeyset(name)
+	if err != nil {
+		return nil, fmt.Errorf("unable to fetch sealed keyset %q: %w", name, err)
+	}
+	if wrapper == nil || len(wrapper.keys) == 0 {
+		return nil, fmt.Errorf("tinycrypto: sealed keyset %q not found", name)
+	}
+	plaintext, err := DecryptWithKey(sk.Master, wrapper.keys[0].Value, nil)
+	if err != nil {
+		return nil, fmt.Errorf("unable to open sealed keyset %q: %w", name, err)
+	}
+	keyset := NewKeyset()
+	if err := keyset.UnmarshalBinary(plaintext); err != nil {
+		return nil, fmt.Errorf("unable to unmarshal keyset %q: %w", name, err)
+	}
+	return keyset, nil
+}
@@ -0,0 +1,148 @@
+package tinycrypto
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+var errKeysetNotFound = errors.New("keyset not found")
+
+// memCryptoKeyStore is an in-memory CryptoKeyStore for tests, standing in for
+// a real backend (file, database, etc.).
+type memCryptoKeyStore struct {
+	keysets map[string]*Keyset
+}
+
+func newMemCryptoKeyStore() *memCryptoKeyStore {
+	return &memCryptoKeyStore{keysets: make(map[string]*Keyset)}
+}
+
+func (s *memCryptoKeyStore) GetCryptoKeyset(name string) (*Keyset, error) {
+	ks, ok := s.keysets[name]
+	if !ok {
+		return nil, errKeysetNotFound
+	}
+	return ks, nil
+}
+
+func (s *memCryptoKeyStore) PutCryptoKeyset(name string, keyset *Keyset) error {
+	s.keysets[name] = keyset
+	return nil
+}
+
+func TestKeyset_MarshalUnmarshalBinary(t *testing.T) {
+	key1, _ := NewRandomKey()
+	key2, _ := NewRandomKey()
+	key2.Algorithm = AlgChaCha20Poly1305
+	key2.ExpiresUnix = time.Now().Add(time.Hour).Unix()
+
+	ks := NewKeysetWithKey(key1)
+	ks.RotateIn(key2, time.Hour)
+	ks.TypeID = 7
+
+	data, err := ks.MarshalBinary()
+	if err != nil {
+		t.Fatalf("unable to marshal keyset: %s", err.Error())
+	}
+
+	restored := NewKeyset()
+	if err := restored.UnmarshalBinary(data); err != nil {
+		t.Fatalf("unable to unmarshal keyset: %s", err.Error())
+	}
+
+	if restored.TypeID != ks.TypeID {
+		t.Fatalf("expected TypeID %d, got %d", ks.TypeID, restored.TypeID)
+	}
+	if len(restored.keys) != len(ks.keys) {
+		t.Fatalf("expected %d keys, got %d", len(ks.keys), len(restored.keys))
+	}
+	for i, k := range ks.keys {
+		rk := restored.keys[i]
+		if !bytes.Equal(rk.Value, k.Value) {
+			t.Fatalf("key %d: value mismatch after round trip", i)
+		}
+		if rk.Algorithm != k.Algorithm {
+			t.Fatalf("key %d: algorithm mismatch after round trip", i)
+		}
+		if rk.CreatedUnix != k.CreatedUnix || rk.ExpiresUnix != k.ExpiresUnix {
+			t.Fatalf("key %d: timestamp mismatch after round trip", i)
+		}
+	}
+}
+
+func TestKeyset_UnmarshalBinary_RejectsCorruptValue(t *testing.T) {
+	key, _ := NewRandomKey()
+	ks := NewKeysetWithKey(key)
+	data, err := ks.MarshalBinary()
+	if err != nil {
+		t.Fatalf("unable to marshal keyset: %s", err.Error())
+	}
+	data[len(data)-1] ^= 0xFF // flip every bit of the last byte of the key's Value
+
+	if err := NewKeyset().UnmarshalBinary(data); err == nil {
+		t.Fatal("expected a corrupted key value to fail its integrity check")
+	}
+}
+
+func TestKeyset_UnmarshalBinary_RejectsTruncatedValue(t *testing.T) {
+	key, _ := NewRandomKey()
+	ks := NewKeysetWithKey(key)
+	data, err := ks.MarshalBinary()
+	if err != nil {
+		t.Fatalf("unable to marshal keyset: %s", err.Error())
+	}
+	truncated := data[:len(data)-1] // cut off the last byte of the key's Value
+
+	err = NewKeyset().UnmarshalBinary(truncated)
+	if err == nil {
+		t.Fatal("expected a truncated key value to fail")
+	}
+	if !strings.Contains(err.Error(), "truncated keyset") {
+		t.Fatalf("expected a truncated-keyset error, got: %s", err.Error())
+	}
+}
+
+func TestSealedKeyset_PutGet(t *testing.T) {
+	master, _ := NewRandomKey()
+	store := newMemCryptoKeyStore()
+	sealed := NewSealedKeyset(store, master)
+
+	key, _ := NewRandomKey()
+	ks := NewKeysetWithKey(key)
+
+	if err := sealed.Put("db-creds", ks); err != nil {
+		t.Fatalf("unable to put sealed keyset: %s", err.Error())
+	}
+
+	stored, err := store.GetCryptoKeyset("db-creds")
+	if err != nil {
+		t.Fatalf("unable to fetch raw stored keyset: %s", err.Error())
+	}
+	if bytes.Contains(stored.keys[0].Value, key.Value) {
+		t.Fatal("expected the stored keyset to not contain the plaintext key material")
+	}
+
+	restored, err := sealed.Get("db-creds")
+	if err != nil {
+		t.Fatalf("unable to get sealed keyset: %s", err.Error())
+	}
+	if !bytes.Equal(restored.keys[0].Value, key.Value) {
+		t.Fatal("expected the restored key's value to match the original")
+	}
+}
+
+func TestSealedKeyset_Get_EmptyKeysetIsNotFound(t *testing.T) {
+	master, _ := NewRandomKey()
+	store := newMemCryptoKeyStore()
+	// Some CryptoKeyStore implementations represent "not found" as an empty
+	// Keyset and a nil error, rather than an error.
+	store.keysets["missing"] = NewKeyset()
+	sealed := NewSealedKeyset(store, master)
+
+	if _, err := sealed.Get("missing"); err == nil {
+		t.Fatal("expected Get on an empty stored keyset to return an error, not panic or succeed")
+	}
+}
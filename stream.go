@@ -0,0 +1,374 @@
+package tinycrypto
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+)
+
+// DefaultChunkSize is the plaintext chunk size NewEncryptWriter uses when
+// framing a stream: each chunk is sealed (and authenticated) independently,
+// so a stream can be encrypted and decrypted without holding the whole
+// payload in memory at once.
+const DefaultChunkSize = 1 << 20 // 1 MiB
+
+const (
+	streamMagic   = "TCS1"
+	streamVersion = 1
+)
+
+// writeStreamHeader writes the short, fixed header every tinycrypto stream
+// starts with: a magic string, a version, the algorithm and chunk size in
+// use, the random per-stream nonce prefix, and the encrypting key's ID (see
+// Key.ID), so NewDecryptReader can look the right key up in a Keyset by ID
+// (see Keyset.KeyByID) instead of trial-decrypting against every candidate.
+func writeStreamHeader(dst io.Writer, alg Algorithm, chunkSize uint32, noncePrefix, keyID []byte) error {
+	if len(noncePrefix) > 255 || len(keyID) > 255 {
+		return errors.New("tinycrypto: nonce prefix or key id too long for stream header")
+	}
+	hdr := make([]byte, 0, len(streamMagic)+1+1+4+1+len(noncePrefix)+1+len(keyID))
+	hdr = append(hdr, streamMagic...)
+	hdr = append(hdr, streamVersion, byte(alg))
+	var cs [4]byte
+	binary.BigEndian.PutUint32(cs[:], chunkSize)
+	hdr = append(hdr, cs[:]...)
+	hdr = append(hdr, byte(len(noncePrefix)))
+	hdr = append(hdr, noncePrefix...)
+	hdr = append(hdr, byte(len(keyID)))
+	hdr = append(hdr, keyID...)
+	_, err := dst.Write(hdr)
+	return err
+}
+
+// readStreamHeader reads and validates the header written by
+// writeStreamHeader.
+func readStreamHeader(src io.Reader) (alg Algorithm, chunkSize uint32, noncePrefix, keyID []byte, err error) {
+	fixed := make([]byte, len(streamMagic)+1+1+4+1)
+	if _, err = io.ReadFull(src, fixed); err != nil {
+		return 0, 0, nil, nil, fmt.Errorf("tinycrypto: unable to read stream header: %w", err)
+	}
+	if string(fixed[:len(streamMagic)]) != streamMagic {
+		return 0, 0, nil, nil, errors.New("tinycrypto: not a tinycrypto stream (bad magic)")
+	}
+	i := len(streamMagic)
+	version := fixed[i]
+	i++
+	if version != streamVersion {
+		return 0, 0, nil, nil, fmt.Errorf("tinycrypto: unsupported stream version %d", version)
+	}
+	alg = Algorithm(fixed[i])
+	i++
+	if !alg.valid() {
+		return 0, 0, nil, nil, fmt.Errorf("tinycrypto: unrecognized stream algorithm id %d", alg)
+	}
+	chunkSize = binary.BigEndian.Uint32(fixed[i : i+4])
+	i += 4
+	noncePrefixLen := int(fixed[i])
+
+	noncePrefix = make([]byte, noncePrefixLen)
+	if _, err = io.ReadFull(src, noncePrefix); err != nil {
+		return 0, 0, nil, nil, fmt.Errorf("tinycrypto: unable to read stream nonce prefix: %w", err)
+	}
+	var keyIDLenBuf [1]byte
+	if _, err = io.ReadFull(src, keyIDLenBuf[:]); err != nil {
+		return 0, 0, nil, nil, fmt.Errorf("tinycrypto: unable to read stream key id length: %w", err)
+	}
+	keyID = make([]byte, keyIDLenBuf[0])
+	if _, err = io.ReadFull(src, keyID); err != nil {
+		return 0, 0, nil, nil, fmt.Errorf("tinycrypto: unable to read stream key id: %w", err)
+	}
+	return alg, chunkSize, noncePrefix, keyID, nil
+}
+
+// writeFrame writes a single length-prefixed ciphertext chunk.
+func writeFrame(dst io.Writer, chunk []byte) error {
+	var lenBuf [4]byte
+	binary.BigEndian.PutUint32(lenBuf[:], uint32(len(chunk)))
+	if _, err := dst.Write(lenBuf[:]); err != nil {
+		return err
+	}
+	_, err := dst.Write(chunk)
+	return err
+}
+
+// maxFrameOverhead is generous slack added to DefaultChunkSize when bounding
+// how large a frame readFrame will accept, covering any supported AEAD's
+// authentication tag (16 bytes, for every algorithm Key currently supports).
+const maxFrameOverhead = 64
+
+// maxFrameLen is the largest ciphertext frame readFrame will allocate for.
+// NewEncryptWriter never produces a frame larger than this, so a declared
+// frame length above it can only come from a corrupted or malicious stream.
+const maxFrameLen = DefaultChunkSize + maxFrameOverhead
+
+// readFrame reads a single length-prefixed ciphertext chunk, rejecting a
+// declared length over maxFrameLen before allocating, so a corrupted or
+// malicious stream (readers are meant to accept untrusted `io.Reader`
+// sources, e.g. backups) can't force an arbitrarily large allocation ahead
+// of authentication. It returns io.EOF, unmodified, when src is exhausted at
+// a frame boundary (no bytes of a new frame have been read yet); any other
+// error, including a partial frame, indicates a truncated stream.
+func readFrame(src io.Reader) ([]byte, error) {
+	var lenBuf [4]byte
+	if _, err := io.ReadFull(src, lenBuf[:]); err != nil {
+		if err == io.EOF {
+			return nil, io.EOF
+		}
+		return nil, fmt.Errorf("tinycrypto: truncated stream (frame length): %w", err)
+	}
+	frameLen := binary.BigEndian.Uint32(lenBuf[:])
+	if frameLen > maxFrameLen {
+		return nil, fmt.Errorf("tinycrypto: frame length %d exceeds the maximum of %d", frameLen, uint32(maxFrameLen))
+	}
+	chunk := make([]byte, frameLen)
+	if _, err := io.ReadFull(src, chunk); err != nil {
+		return nil, fmt.Errorf("tinycrypto: truncated stream (frame body): %w", err)
+	}
+	return chunk, nil
+}
+
+// streamNonce builds the nonce for chunk number counter: the stream's random
+// prefix, followed by the big-endian counter, filling out the key's nonce
+// size.
+func streamNonce(noncePrefix []byte, counter uint32) []byte {
+	nonce := make([]byte, len(noncePrefix)+4)
+	copy(nonce, noncePrefix)
+	binary.BigEndian.PutUint32(nonce[len(noncePrefix):], counter)
+	return nonce
+}
+
+// chunkAAD authenticates aad together with a flag marking whether this is
+// the stream's final chunk, so a truncated stream (one that ends on a
+// non-final chunk) is detected rather than silently accepted as complete.
+func chunkAAD(aad []byte, last bool) []byte {
+	lastByte := byte(0)
+	if last {
+		lastByte = 1
+	}
+	return append(append([]byte(nil), aad...), lastByte)
+}
+
+// encryptWriter implements NewEncryptWriter's io.WriteCloser.
+type encryptWriter struct {
+	dst         io.Writer
+	key         *Key
+	aad         []byte
+	noncePrefix []byte
+	chunkSize   int
+	buf         []byte
+	counter     uint32
+	closed      bool
+}
+
+// NewEncryptWriter returns an io.WriteCloser that frames everything written
+// to it into DefaultChunkSize plaintext chunks, each sealed independently
+// under key (and authenticating aad, plus an internal marker for the final
+// chunk), and writes the resulting stream to dst. Close must be called to
+// flush the final chunk and its "this is the end of the stream" marker;
+// without it, a reader can't tell a deliberately finished stream from one
+// truncated by an attacker or a flaky connection.
+//
+// Pair this with NewDecryptReader (using the same aad) to read it back.
+func NewEncryptWriter(dst io.Writer, key *Key, aad []byte) (io.WriteCloser, error) {
+	noncePrefixSize := key.NonceSize() - 4
+	if noncePrefixSize < 1 {
+		return nil, errors.New("tinycrypto: key's nonce is too short to carve out a stream counter")
+	}
+	noncePrefix, err := GenerateRandomBytes(uint32(noncePrefixSize))
+	if err != nil {
+		return nil, fmt.Errorf("tinycrypto: unable to generate stream nonce prefix: %w", err)
+	}
+	keyID := key.ID()
+	if err := writeStreamHeader(dst, key.Algorithm, DefaultChunkSize, noncePrefix, keyID[:]); err != nil {
+		return nil, fmt.Errorf("tinycrypto: unable to write stream header: %w", err)
+	}
+	return &encryptWriter{
+		dst:         dst,
+		key:         key,
+		aad:         aad,
+		noncePrefix: noncePrefix,
+		chunkSize:   DefaultChunkSize,
+		buf:         make([]byte, 0, DefaultChunkSize),
+	}, nil
+}
+
+// Write buffers p, sealing and flushing a chunk every time the buffer fills.
+func (w *encryptWriter) Write(p []byte) (int, error) {
+	if w.closed {
+		return 0, errors.New("tinycrypto: write to a closed stream encrypt writer")
+	}
+	written := len(p)
+	for len(p) > 0 {
+		n := copy(w.buf[len(w.buf):w.chunkSize], p)
+		w.buf = w.buf[:len(w.buf)+n]
+		p = p[n:]
+		if len(w.buf) == w.chunkSize {
+			if err := w.sealAndWrite(false); err != nil {
+				return written - len(p), err
+			}
+		}
+	}
+	return written, nil
+}
+
+// Close seals and flushes the final chunk, marking it as such, and
+// finishes the stream. It's safe to call more than once.
+func (w *encryptWriter) Close() error {
+	if w.closed {
+		return nil
+	}
+	w.closed = true
+	return w.sealAndWrite(true)
+}
+
+func (w *encryptWriter) sealAndWrite(last bool) error {
+	nonce := streamNonce(w.noncePrefix, w.counter)
+	ciphertext := w.key.Seal(nil, nonce, w.buf, chunkAAD(w.aad, last))
+	if err := writeFrame(w.dst, ciphertext); err != nil {
+		return fmt.Errorf("tinycrypto: unable to write stream chunk: %w", err)
+	}
+	w.counter++
+	w.buf = w.buf[:0]
+	return nil
+}
+
+// decryptReader implements NewDecryptReader's io.ReadCloser.
+type decryptReader struct {
+	src         io.Reader
+	aad         []byte
+	noncePrefix []byte
+	candidates  []*Key // keys still in the running, until the first chunk picks one
+	key         *Key   // the key that successfully opened the first chunk
+	counter     uint32
+
+	curFrame []byte // raw ciphertext of the not-yet-decrypted current chunk
+	haveCur  bool
+	pending  []byte // decrypted plaintext not yet returned to the caller
+	eof      bool
+}
+
+// NewDecryptReader returns an io.ReadCloser that reads a stream written by
+// NewEncryptWriter from src, authenticating aad (which must match what was
+// passed to NewEncryptWriter) on every chunk. If the stream's header carries
+// a key ID (as NewEncryptWriter now always writes), the matching key is
+// looked up in ks directly; otherwise (a stream from before key IDs existed)
+// it falls back to trying each of ks's unexpired keys that use the stream's
+// algorithm against the first chunk. It returns an error if the stream is
+// truncated (cut off before its marked final chunk) or fails to
+// authenticate.
+func NewDecryptReader(src io.Reader, ks *Keyset, aad []byte) (io.ReadCloser, error) {
+	alg, _, noncePrefix, keyID, err := readStreamHeader(src)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(keyID) == len(KeyID{}) {
+		var id KeyID
+		copy(id[:], keyID)
+		key := ks.KeyByID(id)
+		if key == nil {
+			return nil, fmt.Errorf("tinycrypto: no keyset key with id %x", id)
+		}
+		if key.Algorithm != alg {
+			return nil, fmt.Errorf("tinycrypto: stream algorithm %d does not match key algorithm %d", alg, key.Algorithm)
+		}
+		return &decryptReader{src: src, aad: aad, noncePrefix: noncePrefix, key: key}, nil
+	}
+
+	ks.RLock()
+	now := time.Now().Unix()
+	candidates := make([]*Key, 0, len(ks.keys))
+	for _, k := range ks.keys {
+		if k.Algorithm != alg {
+			continue
+		}
+		if k.ExpiresUnix > 0 && k.ExpiresUnix < now {
+			continue
+		}
+		candidates = append(candidates, k)
+	}
+	ks.RUnlock()
+	if len(candidates) == 0 {
+		return nil, errors.New("tinycrypto: no unexpired keyset key matches the stream's algorithm")
+	}
+
+	return &decryptReader{src: src, aad: aad, noncePrefix: noncePrefix, candidates: candidates}, nil
+}
+
+// Read implements io.Reader, decrypting and returning one chunk at a time.
+func (r *decryptReader) Read(p []byte) (int, error) {
+	if len(r.pending) == 0 {
+		if r.eof {
+			return 0, io.EOF
+		}
+		if err := r.fill(); err != nil {
+			return 0, err
+		}
+	}
+	n := copy(p, r.pending)
+	r.pending = r.pending[n:]
+	return n, nil
+}
+
+// Close implements io.Closer. The underlying source is owned by the caller,
+// so this is a no-op.
+func (r *decryptReader) Close() error {
+	return nil
+}
+
+// fill decrypts the next chunk into r.pending, looking one frame ahead to
+// determine whether the chunk it just read is the stream's final one.
+func (r *decryptReader) fill() error {
+	if !r.haveCur {
+		frame, err := readFrame(r.src)
+		if err != nil {
+			if err == io.EOF {
+				return fmt.Errorf("tinycrypto: truncated stream: missing final chunk: %w", io.ErrUnexpectedEOF)
+			}
+			return err
+		}
+		r.curFrame = frame
+		r.haveCur = true
+	}
+
+	next, nextErr := readFrame(r.src)
+	last := nextErr == io.EOF
+	if nextErr != nil && nextErr != io.EOF {
+		return nextErr
+	}
+
+	nonce := streamNonce(r.noncePrefix, r.counter)
+	aad := chunkAAD(r.aad, last)
+
+	var plaintext []byte
+	if r.key != nil {
+		pt, err := r.key.Open(nil, nonce, r.curFrame, aad)
+		if err != nil {
+			return fmt.Errorf("tinycrypto: chunk %d failed to authenticate: %w", r.counter, err)
+		}
+		plaintext = pt
+	} else {
+		for _, k := range r.candidates {
+			if pt, err := k.Open(nil, nonce, r.curFrame, aad); err == nil {
+				r.key, plaintext = k, pt
+				break
+			}
+		}
+		if r.key == nil {
+			return errors.New("tinycrypto: no valid decryption key for stream")
+		}
+	}
+
+	r.pending = plaintext
+	r.counter++
+	if last {
+		r.eof = true
+		r.haveCur = false
+	} else {
+		r.curFrame = next
+		r.haveCur = true
+	}
+	return nil
+}
@@ -0,0 +1,178 @@
+package tinycrypto
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"testing"
+)
+
+func TestEncryptWriterDecryptReader_RoundTrip(t *testing.T) {
+	key, _ := NewRandomKey()
+	ks := NewKeysetWithKey(key)
+	aad := []byte("file:backup.tar")
+
+	plaintext := bytes.Repeat([]byte("the quick brown fox jumps over the lazy dog"), 100000)
+
+	var encrypted bytes.Buffer
+	w, err := NewEncryptWriter(&encrypted, key, aad)
+	if err != nil {
+		t.Fatalf("unable to create encrypt writer: %s", err.Error())
+	}
+	if _, err := w.Write(plaintext); err != nil {
+		t.Fatalf("the write failed: %s", err.Error())
+	}
+	if err := w.Close(); err != nil {
+		t.Fatalf("the close failed: %s", err.Error())
+	}
+
+	r, err := NewDecryptReader(&encrypted, ks, aad)
+	if err != nil {
+		t.Fatalf("unable to create decrypt reader: %s", err.Error())
+	}
+	decrypted, err := io.ReadAll(r)
+	if err != nil {
+		t.Fatalf("the read failed: %s", err.Error())
+	}
+	if !bytes.Equal(decrypted, plaintext) {
+		t.Fatal("expected decrypted stream to match the original plaintext")
+	}
+}
+
+func TestEncryptWriterDecryptReader_EmptyPayload(t *testing.T) {
+	key, _ := NewRandomKey()
+	ks := NewKeysetWithKey(key)
+
+	var encrypted bytes.Buffer
+	w, err := NewEncryptWriter(&encrypted, key, nil)
+	if err != nil {
+		t.Fatalf("unable to create encrypt writer: %s", err.Error())
+	}
+	if err := w.Close(); err != nil {
+		t.Fatalf("the close failed: %s", err.Error())
+	}
+
+	r, err := NewDecryptReader(&encrypted, ks, nil)
+	if err != nil {
+		t.Fatalf("unable to create decrypt reader: %s", err.Error())
+	}
+	decrypted, err := io.ReadAll(r)
+	if err != nil {
+		t.Fatalf("the read failed: %s", err.Error())
+	}
+	if len(decrypted) != 0 {
+		t.Fatalf("expected no plaintext, got %d bytes", len(decrypted))
+	}
+}
+
+func TestDecryptReader_DetectsTruncation(t *testing.T) {
+	key, _ := NewRandomKey()
+	ks := NewKeysetWithKey(key)
+
+	plaintext := bytes.Repeat([]byte("x"), DefaultChunkSize+10)
+
+	var encrypted bytes.Buffer
+	w, _ := NewEncryptWriter(&encrypted, key, nil)
+	_, _ = w.Write(plaintext)
+	_ = w.Close()
+
+	truncated := encrypted.Bytes()[:encrypted.Len()-5]
+	r, err := NewDecryptReader(bytes.NewReader(truncated), ks, nil)
+	if err != nil {
+		t.Fatalf("unable to create decrypt reader: %s", err.Error())
+	}
+	if _, err := io.ReadAll(r); err == nil {
+		t.Fatal("expected truncation to be detected, got nil error")
+	}
+}
+
+func TestDecryptReader_RejectsOversizedFrameLengthBeforeAllocating(t *testing.T) {
+	key, _ := NewRandomKey()
+	ks := NewKeysetWithKey(key)
+
+	var malicious bytes.Buffer
+	noncePrefix, _ := GenerateRandomBytes(uint32(key.NonceSize() - 4))
+	if err := writeStreamHeader(&malicious, key.Algorithm, DefaultChunkSize, noncePrefix, nil); err != nil {
+		t.Fatalf("unable to write stream header: %s", err.Error())
+	}
+	var lenBuf [4]byte
+	binary.BigEndian.PutUint32(lenBuf[:], 0x7FFFFFFF)
+	malicious.Write(lenBuf[:])
+
+	r, err := NewDecryptReader(&malicious, ks, nil)
+	if err != nil {
+		t.Fatalf("unable to create decrypt reader: %s", err.Error())
+	}
+	if _, err := io.ReadAll(r); err == nil {
+		t.Fatal("expected an oversized declared frame length to be rejected")
+	}
+}
+
+func TestDecryptReader_UnknownKeyID(t *testing.T) {
+	key, _ := NewRandomKey()
+
+	var encrypted bytes.Buffer
+	w, _ := NewEncryptWriter(&encrypted, key, nil)
+	_, _ = w.Write([]byte("secret"))
+	_ = w.Close()
+
+	otherKey, _ := NewRandomKey()
+	otherKS := NewKeysetWithKey(otherKey)
+	if _, err := NewDecryptReader(&encrypted, otherKS, nil); err == nil {
+		t.Fatal("expected a stream key id unknown to the keyset to fail")
+	}
+}
+
+func TestDecryptReader_FallsBackToTrialDecryptionForLegacyStreamsWithoutKeyID(t *testing.T) {
+	key, _ := NewRandomKey()
+	ks := NewKeysetWithKey(key)
+
+	var legacy bytes.Buffer
+	noncePrefix, _ := GenerateRandomBytes(uint32(key.NonceSize() - 4))
+	if err := writeStreamHeader(&legacy, key.Algorithm, DefaultChunkSize, noncePrefix, nil); err != nil {
+		t.Fatalf("unable to write legacy stream header: %s", err.Error())
+	}
+	plaintext := []byte("this is my secret value that I must protect")
+	ciphertext := key.Seal(nil, streamNonce(noncePrefix, 0), plaintext, chunkAAD(nil, true))
+	if err := writeFrame(&legacy, ciphertext); err != nil {
+		t.Fatalf("unable to write legacy stream frame: %s", err.Error())
+	}
+
+	r, err := NewDecryptReader(&legacy, ks, nil)
+	if err != nil {
+		t.Fatalf("unable to create decrypt reader for a legacy (no key id) stream: %s", err.Error())
+	}
+	decrypted, err := io.ReadAll(r)
+	if err != nil {
+		t.Fatalf("the read failed: %s", err.Error())
+	}
+	if !bytes.Equal(decrypted, plaintext) {
+		t.Fatal("expected decrypted stream to match the original plaintext")
+	}
+}
+
+func TestDecryptReader_PicksRightKeyFromKeyset(t *testing.T) {
+	oldKey, _ := NewRandomKey()
+	ks := NewKeysetWithKey(oldKey)
+
+	plaintext := []byte("this is my secret value that I must protect")
+	var encrypted bytes.Buffer
+	w, _ := NewEncryptWriter(&encrypted, oldKey, nil)
+	_, _ = w.Write(plaintext)
+	_ = w.Close()
+
+	newKey, _ := NewRandomKey()
+	ks.RotateIn(newKey, 0) // oldKey is still present, just not first
+
+	r, err := NewDecryptReader(&encrypted, ks, nil)
+	if err != nil {
+		t.Fatalf("unable to create decrypt reader: %s", err.Error())
+	}
+	decrypted, err := io.ReadAll(r)
+	if err != nil {
+		t.Fatalf("the read failed: %s", err.Error())
+	}
+	if !bytes.Equal(decrypted, plaintext) {
+		t.Fatal("expected decrypted stream to match the original plaintext")
+	}
+}